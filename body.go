@@ -0,0 +1,71 @@
+package immune
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type BodyType string
+
+const (
+	BodyTypeJSON      BodyType = "json"
+	BodyTypeForm      BodyType = "form"
+	BodyTypeMultipart BodyType = "multipart"
+	BodyTypeRaw       BodyType = "raw"
+	BodyTypeXML       BodyType = "xml"
+)
+
+func (b BodyType) IsValid() bool {
+	switch b {
+	case BodyTypeJSON,
+		BodyTypeForm,
+		BodyTypeMultipart,
+		BodyTypeRaw,
+		BodyTypeXML:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType returns the default content type for the body type,
+// multipart bodies have their boundary appended when the body is encoded
+func (b BodyType) ContentType() string {
+	switch b {
+	case BodyTypeForm:
+		return "application/x-www-form-urlencoded"
+	case BodyTypeMultipart:
+		return "multipart/form-data"
+	case BodyTypeRaw:
+		return "text/plain"
+	case BodyTypeXML:
+		return "application/xml"
+	default:
+		return "application/json"
+	}
+}
+
+func (b BodyType) String() string {
+	return string(b)
+}
+
+func (b *BodyType) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+
+	*b = BodyType(str)
+
+	if !b.IsValid() {
+		return errors.Errorf("unknown body type %s", b.String())
+	}
+
+	return nil
+}
+
+// File is a file part of a multipart request body
+type File struct {
+	FieldName string `json:"field_name"`
+	// Path is the file sent, resolved like the body_file of test cases
+	Path        string `json:"path"`
+	ContentType string `json:"content_type"`
+}
@@ -3,17 +3,109 @@ package immune
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 )
 
 type CallbackConfiguration struct {
-	MaxWaitSeconds uint   `json:"max_wait_seconds"`
-	Port           uint   `json:"port"`
-	Route          string `json:"route"`
-	SSL            bool   `json:"ssl" envconfig:"IMMUNE_SSL"`
-	SSLKeyFile     string `json:"ssl_key_file" envconfig:"IMMUNE_SSL_KEY_FILE"`
-	SSLCertFile    string `json:"ssl_cert_file" envconfig:"IMMUNE_SSL_CERT_FILE"`
-	IDLocation     string `json:"id_location"`
+	MaxWaitSeconds uint `json:"max_wait_seconds"`
+	// Port is the port the callback server listens on, 0 picks a free port
+	Port        uint   `json:"port"`
+	Route       string `json:"route"`
+	SSL         bool   `json:"ssl" envconfig:"IMMUNE_SSL"`
+	SSLKeyFile  string `json:"ssl_key_file" envconfig:"IMMUNE_SSL_KEY_FILE"`
+	SSLCertFile string `json:"ssl_cert_file" envconfig:"IMMUNE_SSL_CERT_FILE"`
+	// IDLocation is where the callback id is placed in test case requests, in the
+	// format {source}:{name} where source is one of body, header or query
+	IDLocation string `json:"id_location"`
+	// IDSource is where callbacks carry their id, either body:{field path} or
+	// header:{name}, it defaults to body:immune_callback_id
+	IDSource string `json:"id_source"`
+	// PublicHost is the host convoy reaches the callback server at, when set any empty
+	// event_target_url is derived from it and the port the receiver is bound to
+	PublicHost string `json:"public_host" envconfig:"IMMUNE_CALLBACK_PUBLIC_HOST"`
+
+	// AutoTLS generates a self-signed CA and a server certificate at startup, they are
+	// used by every ssl receiver that has no ssl_cert_file & ssl_key_file of its own
+	AutoTLS bool `json:"auto_tls" envconfig:"IMMUNE_AUTO_TLS"`
+	// AutoTLSHosts are the dns names & ip addresses of the generated server
+	// certificate, it defaults to localhost, 127.0.0.1 and ::1
+	AutoTLSHosts []string `json:"auto_tls_hosts"`
+	// CACertOutFile is the path the generated CA certificate is written to, for convoy to trust
+	CACertOutFile string `json:"ca_cert_out_file" envconfig:"IMMUNE_CA_CERT_OUT_FILE"`
+	// ClientCertOutFile & ClientKeyOutFile are the paths a client certificate
+	// signed by the generated CA is written to, for convoy to deliver over mutual tls
+	ClientCertOutFile string `json:"client_cert_out_file" envconfig:"IMMUNE_CLIENT_CERT_OUT_FILE"`
+	ClientKeyOutFile  string `json:"client_key_out_file" envconfig:"IMMUNE_CLIENT_KEY_OUT_FILE"`
+
+	// VerifyClientCert requires callbacks to present a client certificate signed
+	// by ClientCAFile, or by the generated CA when ClientCAFile is empty
+	VerifyClientCert bool   `json:"verify_client_cert" envconfig:"IMMUNE_VERIFY_CLIENT_CERT"`
+	ClientCAFile     string `json:"client_ca_file" envconfig:"IMMUNE_CLIENT_CA_FILE"`
+	// RemoteURL is the base url of a callback server started with the receiver command,
+	// when set callbacks are streamed from it instead of starting a local callback server
+	RemoteURL string `json:"remote_url" envconfig:"IMMUNE_CALLBACK_REMOTE_URL"`
+	// LinkToken is the bearer token the driver presents to a remote callback server, the receiver
+	// command requires it. When set, the introspection API requires it too
+	LinkToken string `json:"link_token" envconfig:"IMMUNE_CALLBACK_LINK_TOKEN"`
+
+	// LogFile is the path of a JSONL file every received callback is appended to,
+	// with its sensitive headers masked
+	LogFile string `json:"log_file" envconfig:"IMMUNE_CALLBACK_LOG_FILE"`
+
+	// Receivers are additional named routes callbacks can be received on,
+	// the route & port above make up the default receiver
+	Receivers []CallbackReceiver `json:"receivers"`
+}
+
+// DefaultReceiverName is the name of the receiver made up of
+// the top level route & port of the callback configuration
+const DefaultReceiverName = "default"
+
+// CallbackReceiver is a named route callbacks can be received on
+type CallbackReceiver struct {
+	Name string `json:"name"`
+	// Port defaults to the port of the callback configuration
+	Port        uint   `json:"port"`
+	Route       string `json:"route"`
+	SSL         bool   `json:"ssl"`
+	SSLKeyFile  string `json:"ssl_key_file"`
+	SSLCertFile string `json:"ssl_cert_file"`
+	// EventTargetURL is the url convoy delivers to for this receiver, it is
+	// used by the setup_endpoint:{receiver_name} setup
+	EventTargetURL string `json:"event_target_url"`
+	// Response is how the receiver responds to callbacks, unless
+	// a test case sets its own callback response
+	Response *CallbackResponse `json:"response"`
+	// IDSource overrides the id_source of the callback configuration for this receiver
+	IDSource string `json:"id_source"`
+}
+
+// AllReceivers returns the default receiver followed by the named receivers, with
+// their ports & id sources defaulted to those of the callback configuration
+func (c *CallbackConfiguration) AllReceivers() []CallbackReceiver {
+	receivers := []CallbackReceiver{{
+		Name:        DefaultReceiverName,
+		Port:        c.Port,
+		Route:       c.Route,
+		SSL:         c.SSL,
+		SSLKeyFile:  c.SSLKeyFile,
+		SSLCertFile: c.SSLCertFile,
+		IDSource:    c.IDSource,
+	}}
+
+	for _, r := range c.Receivers {
+		if r.Port == 0 {
+			r.Port = c.Port
+		}
+		if r.IDSource == "" {
+			r.IDSource = c.IDSource
+		}
+		receivers = append(receivers, r)
+	}
+
+	return receivers
 }
 
 const CallbackIDFieldName = "immune_callback_id"
@@ -48,7 +140,29 @@ func InjectCallbackID(field string, value interface{}, r M) error {
 }
 
 type CallbackServer interface {
-	ReceiveCallback(rc chan<- *Signal)
+	// Register tells the server about the callbacks expected for id, so it can respond to them
+	// as described by cb
+	Register(id string, cb *Callback) error
+	// ReceiveCallback sends the next callback to rc, it returns without
+	// sending if ctx is done before a callback arrives. Once the server can no longer
+	// receive callbacks, it sends a Signal carrying the error instead
+	ReceiveCallback(ctx context.Context, rc chan<- *Signal)
+	// History returns every callback received for id, in order of arrival
+	History(id string) []Signal
+	// Port returns the port the named receiver is bound to, it is 0 until the server
+	// is started, or if there is no such receiver
+	Port(receiver string) uint
+	// Start binds the ports of every receiver, it returns once
+	// the server is ready to accept callbacks
 	Start(ctx context.Context) error
 	Stop()
 }
+
+// URL returns the url of the receiver on host, bound to port
+func (r *CallbackReceiver) URL(host string, port uint) string {
+	scheme := "http"
+	if r.SSL {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)), r.Route)
+}
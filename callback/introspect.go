@@ -0,0 +1,118 @@
+package callback
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/frain-dev/immune"
+	log "github.com/sirupsen/logrus"
+)
+
+// IntrospectionRoute is where the callback server exposes the callbacks it has
+// received, webhook routes must not be registered under it
+const IntrospectionRoute = "/_immune/callbacks"
+
+// callbackView is the representation of a received callback in the introspection API
+type callbackView struct {
+	ImmuneCallbackID string    `json:"immune_callback_id"`
+	Attempt          int       `json:"attempt"`
+	StatusCode       int       `json:"status_code"`
+	ReceivedAt       time.Time `json:"received_at"`
+	Receiver         string    `json:"receiver,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+type countView struct {
+	Total int            `json:"total"`
+	ByID  map[string]int `json:"by_id"`
+}
+
+// introspectionMux returns a handler serving the introspection API, the callback server
+// puts it behind the link token when one is set:
+//
+//	GET /_immune/callbacks                          lists every received callback
+//	GET /_immune/callbacks?immune_callback_id={id}  lists the callbacks received for id
+//	GET /_immune/callbacks/pending                  lists expectations still waiting for callbacks
+//	GET /_immune/callbacks/count                    counts the received callbacks per id
+func introspectionMux(reg *registry) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(IntrospectionRoute, getOnly(func(w http.ResponseWriter, r *http.Request) {
+		var signals []immune.Signal
+		if id := r.URL.Query().Get(immune.CallbackIDFieldName); id != "" {
+			signals = reg.historyOf(id)
+		} else {
+			signals = reg.all()
+		}
+
+		views := make([]callbackView, 0, len(signals))
+		for i := range signals {
+			views = append(views, newCallbackView(&signals[i]))
+		}
+		writeJSON(w, views)
+	}))
+
+	mux.HandleFunc(IntrospectionRoute+"/pending", getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, reg.pending())
+	}))
+
+	mux.HandleFunc(IntrospectionRoute+"/count", getOnly(func(w http.ResponseWriter, r *http.Request) {
+		signals := reg.all()
+		count := countView{Total: len(signals), ByID: map[string]int{}}
+		for _, sig := range signals {
+			count.ByID[sig.ImmuneCallBackID]++
+		}
+		writeJSON(w, count)
+	}))
+
+	return mux
+}
+
+func newCallbackView(sig *immune.Signal) callbackView {
+	v := callbackView{
+		ImmuneCallbackID: sig.ImmuneCallBackID,
+		Attempt:          sig.Attempt,
+		StatusCode:       sig.StatusCode,
+		ReceivedAt:       sig.ReceivedAt,
+		Receiver:         sig.Receiver,
+	}
+	if sig.HasError() {
+		v.Error = sig.Error()
+	}
+	return v
+}
+
+// toSignal converts v back into the signal it was created from
+func (v *callbackView) toSignal() *immune.Signal {
+	sig := &immune.Signal{
+		ImmuneCallBackID: v.ImmuneCallbackID,
+		Attempt:          v.Attempt,
+		StatusCode:       v.StatusCode,
+		ReceivedAt:       v.ReceivedAt,
+		Receiver:         v.Receiver,
+	}
+	if v.Error != "" {
+		sig.Err = errors.New(v.Error)
+	}
+	return sig
+}
+
+func getOnly(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.WithError(err).Error("failed to write introspection response")
+	}
+}
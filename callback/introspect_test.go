@@ -0,0 +1,104 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_introspectionMux(t *testing.T) {
+	outbound := make(chan *immune.Signal, 10)
+	reg := newRegistry()
+	reg.register("abc", &immune.Callback{Times: 2})
+	reg.register("def", &immune.Callback{Times: 1, Response: &immune.CallbackResponse{StatusCode: http.StatusInternalServerError}})
+	reg.register("ghi", &immune.Callback{Expect: immune.CallbackExpectNone})
+
+	// the webhook route is registered at the root, as it is by default
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleCallback(&receiver{name: immune.DefaultReceiverName}, outbound, reg, nil, nil))
+	introspection := introspectionMux(reg)
+	mux.Handle(IntrospectionRoute, introspection)
+	mux.Handle(IntrospectionRoute+"/", introspection)
+
+	for _, id := range []string{"abc", "def"} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"`+id+`"}`)))
+		<-outbound
+	}
+
+	get := func(target string, out interface{}) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(out))
+	}
+
+	var all []callbackView
+	get(IntrospectionRoute, &all)
+	require.Len(t, all, 2)
+	require.Equal(t, "abc", all[0].ImmuneCallbackID)
+	require.Equal(t, http.StatusOK, all[0].StatusCode)
+	require.Equal(t, "def", all[1].ImmuneCallbackID)
+	require.Equal(t, http.StatusInternalServerError, all[1].StatusCode)
+
+	var filtered []callbackView
+	get(IntrospectionRoute+"?immune_callback_id=def", &filtered)
+	require.Len(t, filtered, 1)
+	require.Equal(t, 1, filtered[0].Attempt)
+
+	var pending []pendingExpectation
+	get(IntrospectionRoute+"/pending", &pending)
+	require.Equal(t, []pendingExpectation{{ImmuneCallbackID: "abc", Expected: 2, Received: 1}}, pending)
+
+	var count countView
+	get(IntrospectionRoute+"/count", &count)
+	require.Equal(t, countView{Total: 2, ByID: map[string]int{"abc": 1, "def": 1}}, count)
+
+	// introspection requests never reach the webhook route
+	require.Len(t, outbound, 0)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, IntrospectionRoute, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestNewServer_IntrospectionToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs, err := NewServer(&immune.CallbackConfiguration{Route: "/cb", LinkToken: "secret"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, cs.Start(ctx))
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", cs.Port(immune.DefaultReceiverName), IntrospectionRoute)
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "should_reject_missing_token", wantStatus: http.StatusUnauthorized},
+		{name: "should_reject_wrong_token", auth: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "should_accept_token", auth: "Bearer secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
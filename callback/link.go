@@ -0,0 +1,157 @@
+package callback
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/redact"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// LinkRoute is where a callback server started by the receiver command serves
+// the test driver, webhook routes must not be registered under it
+const LinkRoute = "/_immune/link"
+
+// registration is the body of a register request sent over the link
+type registration struct {
+	ImmuneCallbackID string           `json:"immune_callback_id"`
+	Callback         *immune.Callback `json:"callback"`
+}
+
+// ServeRemote runs a callback server for a test driver on another machine until ctx
+// is done. Received callbacks are streamed to the driver over the link, see linkMux.
+// The link token of cfg is required, without it anyone reaching the server could take
+// the callbacks of the driver or register their responses. redactor masks the headers
+// written to the callback log file and may be nil
+func ServeRemote(ctx context.Context, cfg *immune.CallbackConfiguration, redactor *redact.Redactor) error {
+	if cfg.LinkToken == "" {
+		return errors.New("a link_token is required to serve a remote test driver")
+	}
+
+	s, err := newServer(cfg, redactor)
+	if err != nil {
+		return err
+	}
+
+	link := s.linkMux(cfg.LinkToken)
+	for _, l := range s.listeners {
+		l.mux.Handle(LinkRoute+"/", link)
+	}
+
+	// the server is stopped below, once the streams to the driver are ended
+	err = s.Start(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range cfg.AllReceivers() {
+		log.Infof("callback receiver %s listening on port %d, route %s", r.Name, s.Port(r.Name), r.Route)
+	}
+
+	<-ctx.Done()
+	s.Stop()
+	<-s.shutdown
+	return nil
+}
+
+// linkMux returns a handler serving the test driver of a remote callback server:
+//
+//	GET  /_immune/link/signals   streams received callbacks as newline delimited json
+//	POST /_immune/link/register  registers the callbacks expected for an id
+//
+// The driver must present token as a bearer token
+func (s *server) linkMux(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	var subscribed int32
+	mux.HandleFunc(LinkRoute+"/signals", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// signals are consumed by the subscriber, a second one would steal them
+		if !atomic.CompareAndSwapInt32(&subscribed, 0, 1) {
+			http.Error(w, "a test driver is already subscribed", http.StatusConflict)
+			return
+		}
+		defer atomic.StoreInt32(&subscribed, 0)
+
+		s.streamSignals(w, r)
+	})
+
+	mux.HandleFunc(LinkRoute+"/register", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		reg := &registration{}
+		err := json.NewDecoder(r.Body).Decode(reg)
+		if err != nil || reg.ImmuneCallbackID == "" || reg.Callback == nil {
+			http.Error(w, "invalid registration", http.StatusBadRequest)
+			return
+		}
+
+		s.Register(reg.ImmuneCallbackID, reg.Callback)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return withToken(token, mux)
+}
+
+// streamSignals writes every signal received by s to w until the subscriber disconnects
+func (s *server) streamSignals(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush() // lets the driver know it is subscribed
+
+	log.Infof("test driver %s subscribed", r.RemoteAddr)
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case sig := <-s.outbound:
+			err := enc.Encode(newCallbackView(sig))
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					immune.LogFieldCallbackID: sig.ImmuneCallBackID,
+					immune.LogFieldAttempt:    sig.Attempt,
+				}).Errorf("failed to stream callback %s", sig.ImmuneCallBackID)
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			log.Infof("test driver %s unsubscribed", r.RemoteAddr)
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// withToken requires the requests to next to present token as a bearer token, unless token is empty
+func withToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,124 @@
+package callback
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frain-dev/immune/redact"
+	"github.com/pkg/errors"
+)
+
+// BodyEncodingBase64 is the body encoding of records whose body is not valid UTF-8
+const BodyEncodingBase64 = "base64"
+
+// Record is a single request received by the callback server
+type Record struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+	// BodyEncoding is empty for UTF-8 bodies, or BodyEncodingBase64
+	BodyEncoding string    `json:"body_encoding,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+	RemoteAddr   string    `json:"remote_addr"`
+}
+
+// setBody stores body in rec, base64 encoded when it is not valid UTF-8,
+// so that it is replayed byte for byte
+func (rec *Record) setBody(body []byte) {
+	if utf8.Valid(body) {
+		rec.Body, rec.BodyEncoding = string(body), ""
+		return
+	}
+	rec.Body, rec.BodyEncoding = base64.StdEncoding.EncodeToString(body), BodyEncodingBase64
+}
+
+// RawBody returns the body of rec as it was received
+func (rec *Record) RawBody() ([]byte, error) {
+	switch rec.BodyEncoding {
+	case "":
+		return []byte(rec.Body), nil
+	case BodyEncodingBase64:
+		return base64.StdEncoding.DecodeString(rec.Body)
+	default:
+		return nil, errors.Errorf("unknown body_encoding %s", rec.BodyEncoding)
+	}
+}
+
+// recordedSensitiveHeaders are masked in the log file in addition to the sensitive fields
+// of the redactor, a signature would let anyone holding the log forge callbacks
+var recordedSensitiveHeaders = []string{"signature"}
+
+// recorder appends every received request to a JSONL file, one Record per line.
+// Sensitive headers are masked, their values are not replayed
+type recorder struct {
+	mu       sync.Mutex
+	f        *os.File
+	enc      *json.Encoder
+	redactor *redact.Redactor
+}
+
+// newRecorder opens the log file at path, redactor masks the recorded headers
+// and may be nil for the default sensitive fields
+func newRecorder(path string, redactor *redact.Redactor) (*recorder, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open callback log file")
+	}
+
+	if redactor == nil {
+		redactor = redact.New()
+	}
+
+	return &recorder{f: f, enc: json.NewEncoder(f), redactor: redactor}, nil
+}
+
+// record writes rec to the log file with its sensitive headers masked,
+// it does nothing on a nil recorder
+func (r *recorder) record(rec *Record) error {
+	if r == nil {
+		return nil
+	}
+
+	masked := *rec
+	masked.Headers = r.redactor.Header(rec.Headers)
+	for name, values := range masked.Headers {
+		if isRecordedSensitive(name) {
+			for i := range values {
+				values[i] = redact.Mask
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enc.Encode(&masked)
+}
+
+func isRecordedSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range recordedSensitiveHeaders {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recorder) close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.f.Close()
+}
@@ -0,0 +1,137 @@
+package callback
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/frain-dev/immune"
+)
+
+// registry keeps track of the registered callback ids, how the server
+// should respond to them, and the arrival history of each
+type registry struct {
+	mu        sync.Mutex
+	callbacks map[string]*immune.Callback
+	// attempts & history are kept per receiver, an event delivered to several
+	// receivers is a delivery to each of them rather than retries of one delivery
+	attempts map[delivery]int
+	history  map[delivery][]immune.Signal
+	// every received callback in order of arrival
+	received []immune.Signal
+}
+
+// delivery identifies the attempts of a callback id at a receiver
+type delivery struct {
+	id       string
+	receiver string
+}
+
+func newRegistry() *registry {
+	return &registry{
+		callbacks: map[string]*immune.Callback{},
+		attempts:  map[delivery]int{},
+		history:   map[delivery][]immune.Signal{},
+	}
+}
+
+func (r *registry) register(id string, cb *immune.Callback) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.callbacks[id] = cb
+}
+
+// next records a new attempt for id at receiver, it returns the attempt number and the
+// response to be sent for it, fallback is used if no response is registered for id
+func (r *registry) next(id, receiver string, fallback *immune.CallbackResponse) (int, immune.CallbackResponseStep) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := delivery{id: id, receiver: receiver}
+	r.attempts[key]++
+	attempt := r.attempts[key]
+
+	resp := fallback
+	if cb, ok := r.callbacks[id]; ok && cb.Response != nil {
+		resp = cb.Response
+	}
+
+	return attempt, resp.Step(attempt)
+}
+
+// record adds a copy of sig to the arrival history of its callback id at its receiver
+func (r *registry) record(sig *immune.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := delivery{id: sig.ImmuneCallBackID, receiver: sig.Receiver}
+	r.history[key] = append(r.history[key], *sig)
+	r.received = append(r.received, *sig)
+}
+
+// historyOf returns a copy of the arrival history of id at every receiver, in order of arrival
+func (r *registry) historyOf(id string) []immune.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := []immune.Signal{}
+	for _, sig := range r.received {
+		if sig.ImmuneCallBackID == id {
+			history = append(history, sig)
+		}
+	}
+	return history
+}
+
+// countOf returns the number of callbacks received for id at every receiver, r.mu must be held
+func (r *registry) countOf(id string) uint {
+	var count uint
+	for key, history := range r.history {
+		if key.id == id {
+			count += uint(len(history))
+		}
+	}
+	return count
+}
+
+// all returns a copy of every received callback in order of arrival
+func (r *registry) all() []immune.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	received := make([]immune.Signal, len(r.received))
+	copy(received, r.received)
+	return received
+}
+
+// pendingExpectation is a registered callback id that has not received
+// the minimum number of callbacks expected for it
+type pendingExpectation struct {
+	ImmuneCallbackID string `json:"immune_callback_id"`
+	Expected         uint   `json:"expected"`
+	Received         uint   `json:"received"`
+}
+
+// pending returns the registered callback ids still waiting for callbacks, sorted by id
+func (r *registry) pending() []pendingExpectation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := []pendingExpectation{}
+	for id, cb := range r.callbacks {
+		if cb.ExpectsNone() {
+			continue
+		}
+
+		lower, _ := cb.Bounds()
+		received := r.countOf(id)
+		if received < lower {
+			pending = append(pending, pendingExpectation{ImmuneCallbackID: id, Expected: lower, Received: received})
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ImmuneCallbackID < pending[j].ImmuneCallbackID
+	})
+	return pending
+}
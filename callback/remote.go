@@ -0,0 +1,195 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// remoteServer is the test driver's end of the link to a callback server started by the
+// receiver command on another machine. Callbacks are streamed from it once started
+type remoteServer struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	// all streamed callbacks are sent on this channel
+	outbound chan *immune.Signal
+
+	// closed by Stop, it ends the stream
+	stop chan struct{}
+
+	// closed when the stream ends before Stop, lostErr is why
+	lost    chan struct{}
+	lostErr error
+}
+
+// NewRemoteServer returns a callback server that streams callbacks
+// from the remote callback server at cfg.RemoteURL
+func NewRemoteServer(cfg *immune.CallbackConfiguration, client *http.Client) immune.CallbackServer {
+	return &remoteServer{
+		baseURL:  strings.TrimSuffix(cfg.RemoteURL, "/"),
+		token:    cfg.LinkToken,
+		client:   client,
+		outbound: make(chan *immune.Signal),
+		stop:     make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+}
+
+// Start subscribes to the callbacks of the remote callback server,
+// it returns once the subscription is accepted
+func (rs *remoteServer) Start(ctx context.Context) error {
+	sctx, cancel := context.WithCancel(ctx)
+
+	resp, err := rs.do(sctx, http.MethodGet, LinkRoute+"/signals", nil)
+	if err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to subscribe to remote callback server")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return errors.Errorf("failed to subscribe to remote callback server: status %d", resp.StatusCode)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-rs.stop:
+		}
+		cancel() // ends the stream below
+	}()
+
+	go rs.stream(resp.Body)
+
+	log.Infof("subscribed to remote callback server %s", rs.baseURL)
+	return nil
+}
+
+// stream decodes the callbacks in body, sending each on the outbound channel. If the
+// stream ends before Stop, every later ReceiveCallback sends the error, see lose
+func (rs *remoteServer) stream(body io.ReadCloser) {
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		v := &callbackView{}
+		err := dec.Decode(v)
+		if err != nil {
+			select {
+			case <-rs.stop:
+			default:
+				rs.lose(err)
+			}
+			return
+		}
+
+		select {
+		case rs.outbound <- v.toSignal():
+		case <-rs.stop:
+			return
+		}
+	}
+}
+
+// lose records why the stream ended, callbacks can't be received from then on
+func (rs *remoteServer) lose(err error) {
+	if err == io.EOF {
+		err = errors.New("the stream was closed")
+	}
+	rs.lostErr = errors.Wrap(err, "lost the link to the remote callback server")
+	log.WithError(err).Error("lost the link to the remote callback server")
+	close(rs.lost)
+}
+
+// ReceiveCallback sends a Signal to rc, unless ctx is done first. Once the link is
+// lost it sends a Signal carrying the error, so that the run stops
+func (rs *remoteServer) ReceiveCallback(ctx context.Context, rc chan<- *immune.Signal) {
+	select {
+	case sig := <-rs.outbound:
+		rc <- sig
+	case <-rs.lost:
+		rc <- &immune.Signal{Err: rs.lostErr}
+	case <-ctx.Done():
+	}
+}
+
+// Register registers cb for the callback id on the remote callback server
+func (rs *remoteServer) Register(id string, cb *immune.Callback) error {
+	b, err := json.Marshal(&registration{ImmuneCallbackID: id, Callback: cb})
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode registration of callback %s", id)
+	}
+
+	resp, err := rs.do(context.Background(), http.MethodPost, LinkRoute+"/register", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrapf(err, "failed to register callback %s with remote callback server", id)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return errors.Errorf("failed to register callback %s with remote callback server: status %d", id, resp.StatusCode)
+	}
+	return nil
+}
+
+// History returns every callback the remote callback server received for id, in order of arrival
+func (rs *remoteServer) History(id string) []immune.Signal {
+	path := IntrospectionRoute + "?" + url.Values{immune.CallbackIDFieldName: []string{id}}.Encode()
+	resp, err := rs.do(context.Background(), http.MethodGet, path, nil)
+	if err != nil {
+		log.WithError(err).WithField(immune.LogFieldCallbackID, id).Errorf("failed to fetch history of callback %s", id)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var views []callbackView
+	err = json.NewDecoder(resp.Body).Decode(&views)
+	if err != nil {
+		log.WithError(err).WithField(immune.LogFieldCallbackID, id).Errorf("failed to decode history of callback %s", id)
+		return nil
+	}
+
+	history := make([]immune.Signal, 0, len(views))
+	for i := range views {
+		history = append(history, *views[i].toSignal())
+	}
+	return history
+}
+
+// Port returns 0, the ports of a remote callback server are not known to the driver
+func (rs *remoteServer) Port(receiver string) uint {
+	return 0
+}
+
+// Stop ends the stream of callbacks
+func (rs *remoteServer) Stop() {
+	close(rs.stop)
+}
+
+func (rs *remoteServer) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rs.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if rs.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rs.token)
+	}
+
+	return rs.client.Do(req)
+}
@@ -0,0 +1,176 @@
+package callback
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+const receiverPortEnv = "IMMUNE_TEST_RECEIVER_PORT"
+
+// TestHelperReceiverProcess is the receiver process of TestRemoteServer, it does nothing in a normal test run
+func TestHelperReceiverProcess(t *testing.T) {
+	port := os.Getenv(receiverPortEnv)
+	if port == "" {
+		return
+	}
+
+	p, err := strconv.ParseUint(port, 10, 64)
+	require.NoError(t, err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	err = ServeRemote(ctx, &immune.CallbackConfiguration{Port: uint(p), Route: "/cb", LinkToken: "secret"}, nil)
+	require.NoError(t, err)
+	os.Exit(0)
+}
+
+func TestServeRemote_RequiresToken(t *testing.T) {
+	err := ServeRemote(context.Background(), &immune.CallbackConfiguration{Route: "/cb"}, nil)
+	require.Error(t, err)
+	require.Equal(t, "a link_token is required to serve a remote test driver", err.Error())
+}
+
+func TestRemoteServer(t *testing.T) {
+	port := freePort(t)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperReceiverProcess$")
+	cmd.Env = append(os.Environ(), receiverPortEnv+"="+strconv.Itoa(port))
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		_ = cmd.Wait()
+	}()
+
+	baseURL := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a driver with the wrong token is rejected
+	var err error
+	for i := 0; i < 50; i++ { // wait for the receiver process to start
+		err = NewRemoteServer(&immune.CallbackConfiguration{RemoteURL: baseURL, LinkToken: "wrong"}, http.DefaultClient).Start(ctx)
+		if err == nil || !strings.Contains(err.Error(), "connection refused") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Error(t, err)
+	require.Equal(t, "failed to subscribe to remote callback server: status 401", err.Error())
+
+	cs := NewRemoteServer(&immune.CallbackConfiguration{RemoteURL: baseURL, LinkToken: "secret"}, http.DefaultClient)
+	require.NoError(t, cs.Start(ctx))
+	defer cs.Stop()
+
+	err = cs.Register("abc", &immune.Callback{Response: &immune.CallbackResponse{StatusCode: http.StatusAccepted}})
+	require.NoError(t, err)
+
+	sigChan := make(chan *immune.Signal, 1)
+	go cs.ReceiveCallback(ctx, sigChan)
+
+	resp, err := http.Post(baseURL+"/cb", "application/json", strings.NewReader(`{"immune_callback_id":"abc"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	select {
+	case sig := <-sigChan:
+		require.Equal(t, "abc", sig.ImmuneCallBackID)
+		require.Equal(t, 1, sig.Attempt)
+		require.Equal(t, http.StatusAccepted, sig.StatusCode)
+		require.Equal(t, immune.DefaultReceiverName, sig.Receiver)
+		require.False(t, sig.ReceivedAt.IsZero())
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not streamed from the receiver process")
+	}
+
+	history := cs.History("abc")
+	require.Len(t, history, 1)
+	require.Equal(t, http.StatusAccepted, history[0].StatusCode)
+}
+
+func TestRemoteServer_LostLink(t *testing.T) {
+	// the receiver accepts the subscription, then ends the stream
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	cs := NewRemoteServer(&immune.CallbackConfiguration{RemoteURL: receiver.URL, LinkToken: "secret"}, http.DefaultClient)
+	require.NoError(t, cs.Start(context.Background()))
+	defer cs.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// every receive after the link is lost reports it
+	for i := 0; i < 2; i++ {
+		sigChan := make(chan *immune.Signal, 1)
+		cs.ReceiveCallback(ctx, sigChan)
+		require.Len(t, sigChan, 1)
+
+		sig := <-sigChan
+		require.True(t, sig.HasError())
+		require.Equal(t, "lost the link to the remote callback server: the stream was closed", sig.Error())
+	}
+}
+
+func TestRemoteServer_Register(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:       "should_register_callback",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "should_error_for_rejected_registration",
+			statusCode: http.StatusUnauthorized,
+			wantErr:    true,
+			wantErrMsg: "failed to register callback abc with remote callback server: status 401",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, LinkRoute+"/register", r.URL.Path)
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer receiver.Close()
+
+			cs := NewRemoteServer(&immune.CallbackConfiguration{RemoteURL: receiver.URL, LinkToken: "secret"}, http.DefaultClient)
+			err := cs.Register("abc", &immune.Callback{})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,97 @@
+package callback
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// headers that describe the original connection, they are not replayed
+var skipReplayHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Connection":        true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+	"Accept-Encoding":   true,
+}
+
+// Replay re-sends every request recorded in logFile to the url to, it returns the
+// number of requests replayed. Non 2xx responses are logged but do not stop the replay.
+// Headers masked when recorded are replayed masked
+func Replay(ctx context.Context, client *http.Client, logFile string, to string) (int, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open callback log file")
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		rec := &Record{}
+		err = json.Unmarshal(line, rec)
+		if err != nil {
+			return count, errors.Wrapf(err, "failed to decode record %d", count+1)
+		}
+
+		statusCode, err := replayRecord(ctx, client, rec, to)
+		if err != nil {
+			return count, errors.Wrapf(err, "failed to replay record %d", count+1)
+		}
+		count++
+
+		if statusCode < 200 || statusCode > 299 {
+			log.Warnf("replayed record %d received at %s: got status code %d", count, rec.ReceivedAt, statusCode)
+			continue
+		}
+		log.Infof("replayed record %d received at %s: got status code %d", count, rec.ReceivedAt, statusCode)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return count, errors.Wrap(err, "failed to read callback log file")
+	}
+
+	return count, nil
+}
+
+func replayRecord(ctx context.Context, client *http.Client, rec *Record, to string) (int, error) {
+	body, err := rec.RawBody()
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, rec.Method, to, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for name, values := range rec.Headers {
+		if skipReplayHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
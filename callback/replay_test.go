@@ -0,0 +1,101 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/redact"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "callbacks.jsonl")
+
+	rec, err := newRecorder(logFile, redact.New())
+	require.NoError(t, err)
+
+	outbound := make(chan *immune.Signal, 2)
+	handleFunc := handleCallback(&receiver{name: immune.DefaultReceiverName}, outbound, newRegistry(), rec, nil)
+
+	// the second body is not valid UTF-8
+	bodies := [][]byte{[]byte(`{"immune_callback_id":"abc"}`), {0x1f, 0x8b, 0x08, 0x00, 0xff, 0xfe}}
+	for _, body := range bodies {
+		r := httptest.NewRequest(http.MethodPost, "/webhook?source=convoy", bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer abc")
+		r.Header.Set("X-Retro-Signature", "signature-abc")
+		r.Header.Set("X-Retro-Event", "event.created")
+		handleFunc(httptest.NewRecorder(), r)
+		<-outbound
+	}
+	require.NoError(t, rec.close())
+
+	// the sensitive headers never reach the log file
+	logged, err := ioutil.ReadFile(logFile)
+	require.NoError(t, err)
+	require.NotContains(t, string(logged), "Bearer abc")
+	require.NotContains(t, string(logged), "signature-abc")
+	require.Contains(t, string(logged), `"body_encoding":"base64"`)
+
+	type replayed struct {
+		method    string
+		body      []byte
+		signature string
+		event     string
+	}
+	received := []replayed{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = append(received, replayed{
+			method:    r.Method,
+			body:      body,
+			signature: r.Header.Get("X-Retro-Signature"),
+			event:     r.Header.Get("X-Retro-Event"),
+		})
+	}))
+	defer receiver.Close()
+
+	count, err := Replay(context.Background(), http.DefaultClient, logFile, receiver.URL)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.Equal(t, []replayed{
+		{method: http.MethodPost, body: bodies[0], signature: redact.Mask, event: "event.created"},
+		{method: http.MethodPost, body: bodies[1], signature: redact.Mask, event: "event.created"},
+	}, received)
+}
+
+func TestRecord_RawBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         []byte
+		wantEncoding string
+	}{
+		{
+			name: "should_store_utf8_body_as_is",
+			body: []byte(`{"name":"café"}`),
+		},
+		{
+			name:         "should_store_binary_body_base64_encoded",
+			body:         []byte{0xff, 0x00, 0xfe},
+			wantEncoding: BodyEncodingBase64,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &Record{}
+			rec.setBody(tt.body)
+			require.Equal(t, tt.wantEncoding, rec.BodyEncoding)
+
+			body, err := rec.RawBody()
+			require.NoError(t, err)
+			require.Equal(t, tt.body, body)
+		})
+	}
+}
@@ -2,18 +2,23 @@ package callback
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/redact"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-// server is a callback server. It will listen for requests on its
-// specified port and parse incoming requests into a *Signal. The
+// server is a callback server. It will listen for requests on the
+// ports of its receivers and parse incoming requests into a *Signal. The
 // resulting *Signal is sent on it's outbound channel.
 // A callback should be received with it's ReceiveCallback method.
 type server struct {
@@ -25,57 +30,144 @@ type server struct {
 	// see Start
 	stop chan struct{}
 
-	// the callback http server
-	s *http.Server
+	// closed once the server has shut down
+	shutdown chan struct{}
 
-	withSSL     bool
-	sslKeyFile  string
-	sslCertFile string
+	// an http server for each port used by the receivers
+	listeners []*listener
+
+	// the listener of each receiver, by receiver name
+	receivers map[string]*listener
+
+	// holds the registered callbacks and their attempts
+	reg *registry
+
+	// records every received request, nil if no log file is configured
+	rec *recorder
+}
+
+// listener is the http server of a single port, all the receivers on
+// a port share its ssl configuration
+type listener struct {
+	s       *http.Server
+	mux     *http.ServeMux
+	withSSL bool
+
+	// the port the listener is bound to, set by Start
+	port uint
+}
+
+// receiver is a named route on the callback server
+type receiver struct {
+	name string
+	// response is used for callbacks whose test case does not set a response
+	response *immune.CallbackResponse
+	// idSource is where callbacks carry their id, nil means the
+	// immune_callback_id field at the top level of the body
+	idSource *immune.CallbackIDLocation
+}
+
+// NewServer instantiates a new callback server, redactor masks the headers
+// written to the callback log file and may be nil
+func NewServer(cfg *immune.CallbackConfiguration, redactor *redact.Redactor) (immune.CallbackServer, error) {
+	return newServer(cfg, redactor)
 }
 
-// NewServer instantiates a new callback server
-func NewServer(cfg *immune.CallbackConfiguration) (immune.CallbackServer, error) {
-	outbound := make(chan *immune.Signal)
+// outboundBufferSize is the number of signals the callback server holds until they are received
+const outboundBufferSize = 256
 
-	mux := http.DefaultServeMux
-	mux.HandleFunc(cfg.Route, handleCallback(outbound))
+func newServer(cfg *immune.CallbackConfiguration, redactor *redact.Redactor) (*server, error) {
+	outbound := make(chan *immune.Signal, outboundBufferSize)
+	reg := newRegistry()
 
-	srv := &http.Server{
-		Addr:    ":" + strconv.FormatUint(uint64(cfg.Port), 10),
-		Handler: mux,
+	ts, err := newTLSSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec *recorder
+	if cfg.LogFile != "" {
+		rec, err = newRecorder(cfg.LogFile, redactor)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	stop := make(chan struct{})
 	s := &server{
-		stop:     make(chan struct{}),
-		outbound: outbound,
-		s:        srv,
+		stop:      stop,
+		shutdown:  make(chan struct{}),
+		outbound:  outbound,
+		receivers: map[string]*listener{},
+		reg:       reg,
+		rec:       rec,
 	}
 
-	if cfg.SSL {
-		s.withSSL = true
-		s.sslKeyFile = cfg.SSLKeyFile
-		s.sslCertFile = cfg.SSLCertFile
+	byPort := map[uint]*listener{}
+	for _, r := range cfg.AllReceivers() {
+		l, ok := byPort[r.Port]
+		if !ok {
+			// every port gets its own mux, so that servers never share routes
+			mux := http.NewServeMux()
+			introspection := withToken(cfg.LinkToken, introspectionMux(reg))
+			mux.Handle(IntrospectionRoute, introspection)
+			mux.Handle(IntrospectionRoute+"/", introspection)
+
+			l = &listener{
+				s: &http.Server{
+					Addr:    ":" + strconv.FormatUint(uint64(r.Port), 10),
+					Handler: mux,
+				},
+				mux: mux,
+			}
+
+			if r.SSL {
+				tlsConfig, err := ts.config(r.SSLCertFile, r.SSLKeyFile)
+				if err != nil {
+					return nil, errors.Wrapf(err, "callback receiver %s", r.Name)
+				}
+				l.withSSL = true
+				l.s.TLSConfig = tlsConfig
+			}
+			s.listeners = append(s.listeners, l)
+			byPort[r.Port] = l
+		}
+
+		s.receivers[r.Name] = l
+		rcv := &receiver{name: r.Name, response: r.Response}
+		if r.IDSource != "" {
+			loc, err := immune.ParseCallbackIDLocation(r.IDSource)
+			if err != nil {
+				return nil, errors.Wrapf(err, "callback receiver %s: id_source", r.Name)
+			}
+			rcv.idSource = &loc
+		}
+		l.mux.HandleFunc(r.Route, handleCallback(rcv, outbound, reg, rec, stop))
 	}
 
 	return s, nil
 }
 
-// Start starts the callback http server
+// Start binds the port of every listener and starts serving on them, once
+// it returns the callback servers are ready to accept connections
 func (s *server) Start(ctx context.Context) error {
-	go func() {
-		var err error
-
-		if s.withSSL {
-			log.Infof("Started callback server with SSL: cert_file: %s, key_file: %s", s.sslCertFile, s.sslKeyFile)
-			err = s.s.ListenAndServeTLS(s.sslCertFile, s.sslKeyFile)
-		} else {
-			err = s.s.ListenAndServe()
+	lns := make([]net.Listener, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		ln, err := net.Listen("tcp", l.s.Addr)
+		if err != nil {
+			for _, opened := range lns {
+				_ = opened.Close()
+			}
+			return errors.Wrapf(err, "failed to listen on %s", l.s.Addr)
 		}
 
-		if err != nil && err != http.ErrServerClosed {
-			log.WithError(err).Fatal("callback server failed to start")
-		}
-	}()
+		l.port = uint(ln.Addr().(*net.TCPAddr).Port)
+		lns = append(lns, ln)
+	}
+
+	for i, l := range s.listeners {
+		go l.serve(lns[i])
+	}
 
 	// watches for context cancellation & the stop channel being closed
 	go func() {
@@ -86,22 +178,120 @@ func (s *server) Start(ctx context.Context) error {
 			s.gracefulShutdown()
 		}
 	}()
-	time.Sleep(time.Second) // allow for the server to start
 	return nil
 }
 
-// handleCallback returns a http.HandlerFunc that handles a request
-// to the callback server
-func handleCallback(outbound chan<- *immune.Signal) http.HandlerFunc {
+func (l *listener) serve(ln net.Listener) {
+	var err error
+
+	if l.withSSL {
+		log.Infof("Started callback server on port %d with SSL, client certificate verification: %v", l.port, l.s.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert)
+		// the certificates are loaded into the tls config by NewServer
+		err = l.s.ServeTLS(ln, "", "")
+	} else {
+		log.Infof("Started callback server on port %d", l.port)
+		err = l.s.Serve(ln)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		log.WithError(err).Fatal("callback server failed to start")
+	}
+}
+
+// handleCallback returns a http.HandlerFunc that handles a request to the receiver rcv,
+// responding as registered for its callback id. The signals of the callbacks are sent on
+// outbound without holding up the responses, see deliver. done is closed once they are
+// no longer received
+func handleCallback(rcv *receiver, outbound chan<- *immune.Signal, reg *registry, rec *recorder, done <-chan struct{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sig := &immune.Signal{}
-		err := json.NewDecoder(r.Body).Decode(sig)
+		sig := &immune.Signal{Receiver: rcv.name, Method: r.Method, Path: r.URL.RequestURI(), Header: r.Header.Clone()}
+		body, err := ioutil.ReadAll(r.Body)
+		sig.ReceivedAt = time.Now()
+		sig.Body = body
+		if err != nil {
+			sig.Err = fmt.Errorf("failed to read callback body: %v", err)
+		}
+
+		record := &Record{
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Headers:    r.Header,
+			ReceivedAt: sig.ReceivedAt,
+			RemoteAddr: r.RemoteAddr,
+		}
+		record.setBody(body)
+		err = rec.record(record)
+		if err != nil {
+			log.WithError(err).WithField(immune.LogFieldCallbackID, sig.ImmuneCallBackID).Error("failed to record callback")
+		}
+
+		if !sig.HasError() {
+			sig.Err = rcv.readCallbackID(r.Header, body, sig)
+		}
+
+		attempt, step := reg.next(sig.ImmuneCallBackID, rcv.name, rcv.response)
+		sig.Attempt = attempt
+
+		if step.DelayMS > 0 {
+			select {
+			case <-time.After(time.Duration(step.DelayMS) * time.Millisecond):
+			case <-r.Context().Done():
+			}
+		}
+
+		if step.Drop {
+			reg.record(sig)
+			deliver(outbound, sig, done)
+			// aborts the handler, the server closes the connection without a response
+			panic(http.ErrAbortHandler)
+		}
+
+		sig.StatusCode = step.StatusCode
+		reg.record(sig)
+		w.WriteHeader(step.StatusCode)
+		deliver(outbound, sig, done)
+	}
+}
+
+// deliver sends sig on outbound without blocking, so the handler of the callback returns its
+// response right away. When outbound is full, sig is sent once there is room, unless done is
+// closed first, in which case sig is logged & dropped
+func deliver(outbound chan<- *immune.Signal, sig *immune.Signal, done <-chan struct{}) {
+	select {
+	case outbound <- sig:
+		return
+	default:
+	}
+
+	go func() {
+		select {
+		case outbound <- sig:
+		case <-done:
+			log.WithFields(log.Fields{
+				immune.LogFieldCallbackID: sig.ImmuneCallBackID,
+				immune.LogFieldAttempt:    sig.Attempt,
+			}).Warnf("dropped callback %s at receiver %s, it was not received before the callback server stopped", sig.ImmuneCallBackID, sig.Receiver)
+		}
+	}()
+}
+
+// readCallbackID sets the callback id of sig from the header or body of a callback
+func (rcv *receiver) readCallbackID(header http.Header, body []byte, sig *immune.Signal) error {
+	if rcv.idSource == nil {
+		err := json.Unmarshal(body, sig)
 		if err != nil {
-			sig.Err = fmt.Errorf("failed to decode callback body: %v", err)
+			return fmt.Errorf("failed to decode callback body: %v", err)
 		}
-		w.WriteHeader(http.StatusOK)
-		outbound <- sig
+		return nil
 	}
+
+	id, err := rcv.idSource.Extract(header, body)
+	if err != nil {
+		return fmt.Errorf("failed to read callback id from %s: %v", rcv.idSource, err)
+	}
+
+	sig.ImmuneCallBackID = id
+	return nil
 }
 
 // Stop closes the stop channel, which signals a graceful shutdown of the server
@@ -111,17 +301,51 @@ func (s *server) Stop() {
 }
 
 func (s *server) gracefulShutdown() {
+	defer close(s.shutdown)
+
 	cctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := s.s.Shutdown(cctx)
+	for _, l := range s.listeners {
+		err := l.s.Shutdown(cctx)
+		if err != nil {
+			log.WithError(err).Error("failed to shutdown callback server")
+		}
+	}
+
+	err := s.rec.close()
 	if err != nil {
-		log.WithError(err).Fatal("failed to shutdown callback server")
+		log.WithError(err).Error("failed to close callback log file")
 	}
 	log.Infof("callback server shutdown gracefully")
 }
 
-// ReceiveCallback sends a Signal to rc
-func (s *server) ReceiveCallback(rc chan<- *immune.Signal) {
-	rc <- <-s.outbound
+// ReceiveCallback sends a Signal to rc, unless ctx is done first
+func (s *server) ReceiveCallback(ctx context.Context, rc chan<- *immune.Signal) {
+	select {
+	case sig := <-s.outbound:
+		rc <- sig
+	case <-ctx.Done():
+	}
+}
+
+// Register registers cb for the callback id, subsequent callbacks
+// carrying id will be responded to as described by cb.Response
+func (s *server) Register(id string, cb *immune.Callback) error {
+	s.reg.register(id, cb)
+	return nil
+}
+
+// Port returns the port the receiver is bound to
+func (s *server) Port(receiver string) uint {
+	l, ok := s.receivers[receiver]
+	if !ok {
+		return 0
+	}
+	return l.port
+}
+
+// History returns every callback received for id, in order of arrival
+func (s *server) History(id string) []immune.Signal {
+	return s.reg.historyOf(id)
 }
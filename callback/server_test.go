@@ -1,10 +1,14 @@
 package callback
 
 import (
+	"context"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/frain-dev/immune"
 	"github.com/stretchr/testify/require"
@@ -30,6 +34,13 @@ func Test_handleCallback(t *testing.T) {
 			request: httptest.NewRequest(http.MethodGet, "/", strings.NewReader(`{"immune_callback_id":"123-4242-13429-4221"}`)),
 			wantSignal: &immune.Signal{
 				ImmuneCallBackID: "123-4242-13429-4221",
+				Attempt:          1,
+				StatusCode:       http.StatusOK,
+				Receiver:         immune.DefaultReceiverName,
+				Method:           http.MethodGet,
+				Path:             "/",
+				Header:           http.Header{},
+				Body:             []byte(`{"immune_callback_id":"123-4242-13429-4221"}`),
 			},
 		},
 		{
@@ -44,7 +55,7 @@ func Test_handleCallback(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handleFunc := handleCallback(tt.args.outbound)
+			handleFunc := handleCallback(&receiver{name: immune.DefaultReceiverName}, tt.args.outbound, newRegistry(), nil, nil)
 
 			recorder := httptest.NewRecorder()
 			handleFunc(recorder, tt.request)
@@ -57,7 +68,11 @@ func Test_handleCallback(t *testing.T) {
 				require.Equal(t, tt.wantErrMsg, s.Error())
 				return
 			}
-			require.Equal(t, tt.wantSignal, <-tt.args.outbound)
+			s := <-tt.args.outbound
+			require.False(t, s.ReceivedAt.IsZero())
+
+			s.ReceivedAt = time.Time{}
+			require.Equal(t, tt.wantSignal, s)
 		})
 	}
 }
@@ -92,9 +107,235 @@ func Test_server_ReceiveCallback(t *testing.T) {
 			if tt.arrangeFn != nil {
 				tt.arrangeFn(s)
 			}
-			s.ReceiveCallback(tt.args.rc)
+			s.ReceiveCallback(context.Background(), tt.args.rc)
 
 			require.Equal(t, tt.wantSignal, <-tt.args.rc)
 		})
 	}
 }
+
+func Test_handleCallback_Response(t *testing.T) {
+	outbound := make(chan *immune.Signal, 10)
+	reg := newRegistry()
+	reg.register("abc", &immune.Callback{
+		Response: &immune.CallbackResponse{
+			Script: []immune.CallbackResponseStep{
+				{StatusCode: http.StatusInternalServerError},
+				{StatusCode: http.StatusBadGateway},
+				{StatusCode: http.StatusOK, DelayMS: 20},
+			},
+		},
+	})
+
+	handleFunc := handleCallback(&receiver{name: immune.DefaultReceiverName}, outbound, reg, nil, nil)
+
+	wantStatusCodes := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK, http.StatusOK}
+	for i, want := range wantStatusCodes {
+		recorder := httptest.NewRecorder()
+		handleFunc(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"abc"}`)))
+		require.Equal(t, want, recorder.Code)
+
+		sig := <-outbound
+		require.Equal(t, i+1, sig.Attempt)
+		require.Equal(t, want, sig.StatusCode)
+	}
+
+	history := reg.historyOf("abc")
+	require.Len(t, history, len(wantStatusCodes))
+	for i, want := range wantStatusCodes {
+		require.Equal(t, i+1, history[i].Attempt)
+		require.Equal(t, want, history[i].StatusCode)
+	}
+
+	// unregistered ids get 200 OK
+	recorder := httptest.NewRecorder()
+	handleFunc(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"def"}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, 1, (<-outbound).Attempt)
+}
+
+func Test_handleCallback_FanOut(t *testing.T) {
+	outbound := make(chan *immune.Signal, 10)
+	reg := newRegistry()
+	reg.register("abc", &immune.Callback{
+		Response: &immune.CallbackResponse{
+			Script: []immune.CallbackResponseStep{
+				{StatusCode: http.StatusInternalServerError},
+				{StatusCode: http.StatusOK},
+			},
+		},
+	})
+
+	billing := handleCallback(&receiver{name: "billing"}, outbound, reg, nil, nil)
+	orders := handleCallback(&receiver{name: "orders"}, outbound, reg, nil, nil)
+
+	// the event is delivered to both receivers, each runs through the script on its own
+	deliveries := []struct {
+		handler        http.HandlerFunc
+		wantReceiver   string
+		wantAttempt    int
+		wantStatusCode int
+	}{
+		{handler: billing, wantReceiver: "billing", wantAttempt: 1, wantStatusCode: http.StatusInternalServerError},
+		{handler: orders, wantReceiver: "orders", wantAttempt: 1, wantStatusCode: http.StatusInternalServerError},
+		{handler: orders, wantReceiver: "orders", wantAttempt: 2, wantStatusCode: http.StatusOK},
+		{handler: billing, wantReceiver: "billing", wantAttempt: 2, wantStatusCode: http.StatusOK},
+	}
+	for _, d := range deliveries {
+		recorder := httptest.NewRecorder()
+		d.handler(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"abc"}`)))
+		require.Equal(t, d.wantStatusCode, recorder.Code)
+
+		sig := <-outbound
+		require.Equal(t, d.wantReceiver, sig.Receiver)
+		require.Equal(t, d.wantAttempt, sig.Attempt)
+	}
+
+	// the history of the callback id covers every receiver, in order of arrival
+	history := reg.historyOf("abc")
+	require.Len(t, history, len(deliveries))
+	for i, d := range deliveries {
+		require.Equal(t, d.wantReceiver, history[i].Receiver)
+		require.Equal(t, d.wantAttempt, history[i].Attempt)
+	}
+	require.Empty(t, reg.pending())
+}
+
+func Test_handleCallback_Drop(t *testing.T) {
+	outbound := make(chan *immune.Signal, 1)
+	reg := newRegistry()
+	reg.register("abc", &immune.Callback{Response: &immune.CallbackResponse{Drop: true}})
+
+	srv := httptest.NewServer(handleCallback(&receiver{name: immune.DefaultReceiverName}, outbound, reg, nil, nil))
+	defer srv.Close()
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	_, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"immune_callback_id":"abc"}`))
+	require.Error(t, err)
+
+	sig := <-outbound
+	require.Equal(t, "abc", sig.ImmuneCallBackID)
+	require.Equal(t, 0, sig.StatusCode)
+}
+
+func Test_handleCallback_ReceiverResponse(t *testing.T) {
+	outbound := make(chan *immune.Signal, 2)
+	reg := newRegistry()
+	reg.register("abc", &immune.Callback{})
+	reg.register("def", &immune.Callback{Response: &immune.CallbackResponse{StatusCode: http.StatusAccepted}})
+
+	rcv := &receiver{name: "failing", response: &immune.CallbackResponse{StatusCode: http.StatusServiceUnavailable}}
+	handleFunc := handleCallback(rcv, outbound, reg, nil, nil)
+
+	// the receiver response is used unless the test case sets its own
+	recorder := httptest.NewRecorder()
+	handleFunc(recorder, httptest.NewRequest(http.MethodPost, "/failing", strings.NewReader(`{"immune_callback_id":"abc"}`)))
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	require.Equal(t, "failing", (<-outbound).Receiver)
+
+	recorder = httptest.NewRecorder()
+	handleFunc(recorder, httptest.NewRequest(http.MethodPost, "/failing", strings.NewReader(`{"immune_callback_id":"def"}`)))
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Equal(t, "failing", (<-outbound).Receiver)
+}
+
+func TestNewServer_EphemeralPorts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two servers on port 0 must not share routes or ports
+	var ports []uint
+	for i := 0; i < 2; i++ {
+		cs, err := NewServer(&immune.CallbackConfiguration{
+			Route:     "/cb",
+			Receivers: []immune.CallbackReceiver{{Name: "secondary", Route: "/secondary"}},
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, uint(0), cs.Port(immune.DefaultReceiverName))
+
+		err = cs.Start(ctx)
+		require.NoError(t, err)
+
+		port := cs.Port(immune.DefaultReceiverName)
+		require.NotZero(t, port)
+		require.Equal(t, port, cs.Port("secondary"))
+		require.Equal(t, uint(0), cs.Port("unknown"))
+		ports = append(ports, port)
+
+		sigChan := make(chan *immune.Signal, 1)
+		go cs.ReceiveCallback(ctx, sigChan)
+
+		// the server is ready as soon as Start returns
+		url := (&immune.CallbackReceiver{Route: "/secondary"}).URL("127.0.0.1", port)
+		resp, err := http.Post(url, "application/json", strings.NewReader(`{"immune_callback_id":"abc"}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+
+		sig := <-sigChan
+		require.Equal(t, "abc", sig.ImmuneCallBackID)
+		require.Equal(t, "secondary", sig.Receiver)
+	}
+
+	require.NotEqual(t, ports[0], ports[1])
+}
+
+func Test_handleCallback_IDSource(t *testing.T) {
+	outbound := make(chan *immune.Signal, 2)
+	rcv := &receiver{
+		name:     immune.DefaultReceiverName,
+		idSource: &immune.CallbackIDLocation{Source: immune.CallbackIDSourceHeader, Name: "X-Callback-ID"},
+	}
+	handleFunc := handleCallback(rcv, outbound, newRegistry(), nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event":"payment.success"}`))
+	req.Header.Set("X-Callback-ID", "abc")
+	handleFunc(httptest.NewRecorder(), req)
+
+	sig := <-outbound
+	require.False(t, sig.HasError())
+	require.Equal(t, "abc", sig.ImmuneCallBackID)
+
+	handleFunc(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+	sig = <-outbound
+	require.Equal(t, "failed to read callback id from header:X-Callback-ID: header X-Callback-ID: not found", sig.Error())
+}
+
+func Test_handleCallback_DoesNotBlock(t *testing.T) {
+	// nobody receives from outbound, the responses must not wait for it
+	outbound := make(chan *immune.Signal)
+	done := make(chan struct{})
+	handleFunc := handleCallback(&receiver{name: immune.DefaultReceiverName}, outbound, newRegistry(), nil, done)
+
+	for _, id := range []string{"abc", "def"} {
+		recorder := httptest.NewRecorder()
+		returned := make(chan struct{})
+		go func() {
+			handleFunc(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"`+id+`"}`)))
+			close(returned)
+		}()
+
+		select {
+		case <-returned:
+		case <-time.After(time.Second):
+			t.Fatalf("handler of callback %s blocked on the outbound channel", id)
+		}
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	// the signals are still received once somebody reads
+	received := []string{(<-outbound).ImmuneCallBackID, (<-outbound).ImmuneCallBackID}
+	require.ElementsMatch(t, []string{"abc", "def"}, received)
+
+	// signals not received before done is closed are dropped
+	handleFunc(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"immune_callback_id":"ghi"}`)))
+	close(done)
+	time.Sleep(20 * time.Millisecond) // lets the pending hand-off see done
+
+	select {
+	case sig := <-outbound:
+		t.Fatalf("got callback %s after done was closed", sig.ImmuneCallBackID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,249 @@
+package callback
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// certValidity is how long generated certificates are valid for
+const certValidity = 24 * time.Hour
+
+var defaultAutoTLSHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// certAuthority is a self-signed CA generated in memory
+type certAuthority struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newCertAuthority() (*certAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate CA key")
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "immune callback CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(certValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create CA certificate")
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CA certificate")
+	}
+
+	return &certAuthority{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// issue creates a certificate signed by the CA, hosts are only used for server certificates.
+// It returns the certificate with its pem encoded certificate and key
+func (ca *certAuthority) issue(commonName string, hosts []string, usage x509.ExtKeyUsage) (tls.Certificate, []byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, errors.Wrap(err, "failed to generate certificate key")
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return tls.Certificate{}, nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, errors.Wrapf(err, "failed to create %s certificate", commonName)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, errors.Wrap(err, "failed to marshal certificate key")
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, errors.Wrap(err, "failed to load generated certificate")
+	}
+
+	return cert, certPEM, keyPEM, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate certificate serial number")
+	}
+	return serial, nil
+}
+
+// tlsSettings holds what the ssl listeners of the callback server share,
+// the generated server certificate and the client verification settings
+type tlsSettings struct {
+	autoCert   *tls.Certificate
+	clientCAs  *x509.CertPool
+	clientAuth tls.ClientAuthType
+}
+
+// newTLSSettings generates the CA and certificates when auto tls is enabled, writing
+// out the files convoy needs, and loads the CA client certificates are verified against
+func newTLSSettings(cfg *immune.CallbackConfiguration) (*tlsSettings, error) {
+	ts := &tlsSettings{clientAuth: tls.NoClientCert}
+
+	var ca *certAuthority
+	if cfg.AutoTLS {
+		var err error
+		ca, err = newCertAuthority()
+		if err != nil {
+			return nil, err
+		}
+
+		hosts := cfg.AutoTLSHosts
+		if len(hosts) == 0 {
+			hosts = defaultAutoTLSHosts
+		}
+
+		cert, _, _, err := ca.issue("immune callback server", hosts, x509.ExtKeyUsageServerAuth)
+		if err != nil {
+			return nil, err
+		}
+		ts.autoCert = &cert
+
+		err = writePEM(cfg.CACertOutFile, ca.certPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.ClientCertOutFile != "" {
+			_, certPEM, keyPEM, err := ca.issue("immune callback client", nil, x509.ExtKeyUsageClientAuth)
+			if err != nil {
+				return nil, err
+			}
+
+			err = writePEM(cfg.ClientCertOutFile, certPEM)
+			if err != nil {
+				return nil, err
+			}
+
+			err = writePEM(cfg.ClientKeyOutFile, keyPEM)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !cfg.VerifyClientCert {
+		return ts, nil
+	}
+
+	ts.clientAuth = tls.RequireAndVerifyClientCert
+	ts.clientCAs = x509.NewCertPool()
+
+	if cfg.ClientCAFile == "" {
+		if ca == nil {
+			return nil, errors.New("verify_client_cert requires client_ca_file or auto_tls")
+		}
+		ts.clientCAs.AddCert(ca.cert)
+		return ts, nil
+	}
+
+	caPEM, err := ioutil.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read client ca file")
+	}
+
+	if !ts.clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.Errorf("no certificates found in client ca file %s", cfg.ClientCAFile)
+	}
+
+	return ts, nil
+}
+
+// config returns the tls config of a listener, certFile & keyFile
+// take precedence over the generated server certificate
+func (ts *tlsSettings) config(certFile, keyFile string) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: ts.clientAuth,
+		ClientCAs:  ts.clientCAs,
+	}
+
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load ssl certificate")
+		}
+		c.Certificates = []tls.Certificate{cert}
+		return c, nil
+	}
+
+	if ts.autoCert == nil {
+		return nil, errors.New("both cert_file and key_file are required for ssl without auto_tls")
+	}
+
+	c.Certificates = []tls.Certificate{*ts.autoCert}
+	return c, nil
+}
+
+// writePEM writes b to path, it does nothing if path is empty
+func writePEM(path string, b []byte) error {
+	if path == "" {
+		return nil
+	}
+
+	err := ioutil.WriteFile(path, b, os.FileMode(0600))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package callback
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newTLSSettings_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &immune.CallbackConfiguration{
+		SSL:               true,
+		AutoTLS:           true,
+		CACertOutFile:     filepath.Join(dir, "ca.pem"),
+		ClientCertOutFile: filepath.Join(dir, "client.pem"),
+		ClientKeyOutFile:  filepath.Join(dir, "client-key.pem"),
+		VerifyClientCert:  true,
+	}
+
+	ts, err := newTLSSettings(cfg)
+	require.NoError(t, err)
+
+	tlsConfig, err := ts.config("", "")
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	caPEM, err := ioutil.ReadFile(cfg.CACertOutFile)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caPEM))
+
+	clientCert, err := tls.LoadX509KeyPair(cfg.ClientCertOutFile, cfg.ClientKeyOutFile)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		certificates []tls.Certificate
+		wantErr      bool
+	}{
+		{
+			name:         "should_accept_client_certificate_signed_by_generated_ca",
+			certificates: []tls.Certificate{clientCert},
+		},
+		{
+			name:    "should_reject_missing_client_certificate",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: tt.certificates},
+			}}
+
+			resp, err := client.Get(srv.URL)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func Test_newTLSSettings_ClientCARequired(t *testing.T) {
+	_, err := newTLSSettings(&immune.CallbackConfiguration{SSL: true, VerifyClientCert: true})
+	require.Error(t, err)
+	require.Equal(t, "verify_client_cert requires client_ca_file or auto_tls", err.Error())
+}
+
+func Test_tlsSettings_config_RequiresCertificate(t *testing.T) {
+	ts, err := newTLSSettings(&immune.CallbackConfiguration{SSL: true})
+	require.NoError(t, err)
+
+	_, err = ts.config("", "")
+	require.Error(t, err)
+}
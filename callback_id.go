@@ -0,0 +1,119 @@
+package immune
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CallbackIDSource is the part of a request a callback id is carried in
+type CallbackIDSource string
+
+const (
+	CallbackIDSourceBody   CallbackIDSource = "body"
+	CallbackIDSourceHeader CallbackIDSource = "header"
+	CallbackIDSourceQuery  CallbackIDSource = "query"
+)
+
+// CallbackIDLocation is where a callback id is placed in, or read from, a request.
+// It is written as {source}:{name} e.g header:X-Immune-Callback-ID, a location
+// without a source refers to the body, for compatibility with id_location
+type CallbackIDLocation struct {
+	Source CallbackIDSource
+	// Name is the field path for the body, or the name of the header or query parameter
+	Name string
+}
+
+// ParseCallbackIDLocation parses s in the format {source}:{name}
+func ParseCallbackIDLocation(s string) (CallbackIDLocation, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) == 1 {
+		if s == "" {
+			return CallbackIDLocation{}, fmt.Errorf("callback id location cannot be empty")
+		}
+		return CallbackIDLocation{Source: CallbackIDSourceBody, Name: s}, nil
+	}
+
+	loc := CallbackIDLocation{Source: CallbackIDSource(parts[0]), Name: parts[1]}
+	switch loc.Source {
+	case CallbackIDSourceBody, CallbackIDSourceHeader, CallbackIDSourceQuery:
+	default:
+		return CallbackIDLocation{}, fmt.Errorf("unknown callback id source %s, use one of body, header or query", parts[0])
+	}
+
+	if loc.Name == "" {
+		return CallbackIDLocation{}, fmt.Errorf("callback id location %s: name cannot be empty", s)
+	}
+
+	return loc, nil
+}
+
+func (l CallbackIDLocation) String() string {
+	return fmt.Sprintf("%s:%s", l.Source, l.Name)
+}
+
+// DefaultCallbackIDSource is where callbacks carry their id unless configured otherwise
+var DefaultCallbackIDSource = CallbackIDLocation{Source: CallbackIDSourceBody, Name: CallbackIDFieldName}
+
+// Extract reads the callback id from a received callback, for the body source
+// Name is the path of the id field itself e.g data.immune_callback_id
+func (l CallbackIDLocation) Extract(header http.Header, body []byte) (string, error) {
+	switch l.Source {
+	case CallbackIDSourceHeader:
+		id := header.Get(l.Name)
+		if id == "" {
+			return "", fmt.Errorf("header %s: not found", l.Name)
+		}
+		return id, nil
+	case CallbackIDSourceBody:
+		m := M{}
+		err := json.Unmarshal(body, &m)
+		if err != nil {
+			return "", err
+		}
+
+		parts := strings.Split(l.Name, ".")
+		parent, err := getM(m, parts[:len(parts)-1])
+		if err != nil {
+			return "", err
+		}
+
+		v, ok := parent[parts[len(parts)-1]]
+		if !ok {
+			return "", fmt.Errorf("field %s: not found", l.Name)
+		}
+
+		id, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("field %s: required type is string but got %T", l.Name, v)
+		}
+		return id, nil
+	default:
+		return "", fmt.Errorf("callback ids cannot be read from the %s", l.Source)
+	}
+}
+
+// InjectCallbackIDCreating works like InjectCallbackID, but creates the objects
+// in field that are missing from r instead of failing
+func InjectCallbackIDCreating(field string, value interface{}, r M) error {
+	nextLevel := r
+	for _, part := range strings.Split(field, ".") {
+		v, ok := nextLevel[part]
+		if !ok {
+			m := map[string]interface{}{}
+			nextLevel[part] = m
+			nextLevel = m
+			continue
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the field %s, is not an object in the request body", part)
+		}
+		nextLevel = m
+	}
+
+	nextLevel[CallbackIDFieldName] = value
+	return nil
+}
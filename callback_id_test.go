@@ -0,0 +1,131 @@
+package immune
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCallbackIDLocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   string
+		want       CallbackIDLocation
+		wantErrMsg string
+	}{
+		{
+			name:     "should_default_to_body",
+			location: "data.ref",
+			want:     CallbackIDLocation{Source: CallbackIDSourceBody, Name: "data.ref"},
+		},
+		{
+			name:     "should_parse_header",
+			location: "header:X-Immune-Callback-ID",
+			want:     CallbackIDLocation{Source: CallbackIDSourceHeader, Name: "X-Immune-Callback-ID"},
+		},
+		{
+			name:     "should_parse_query",
+			location: "query:callback_id",
+			want:     CallbackIDLocation{Source: CallbackIDSourceQuery, Name: "callback_id"},
+		},
+		{
+			name:       "should_error_for_unknown_source",
+			location:   "cookie:id",
+			wantErrMsg: "unknown callback id source cookie, use one of body, header or query",
+		},
+		{
+			name:       "should_error_for_empty_name",
+			location:   "header:",
+			wantErrMsg: "callback id location header:: name cannot be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCallbackIDLocation(tt.location)
+			if tt.wantErrMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCallbackIDLocation_Extract(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   CallbackIDLocation
+		header     http.Header
+		body       string
+		want       string
+		wantErrMsg string
+	}{
+		{
+			name:     "should_extract_from_nested_body_field",
+			location: CallbackIDLocation{Source: CallbackIDSourceBody, Name: "data.meta.id"},
+			body:     `{"data":{"meta":{"id":"123-564"}}}`,
+			want:     "123-564",
+		},
+		{
+			name:     "should_extract_from_header",
+			location: CallbackIDLocation{Source: CallbackIDSourceHeader, Name: "X-Callback-ID"},
+			header:   http.Header{"X-Callback-Id": []string{"123-564"}},
+			body:     "not json",
+			want:     "123-564",
+		},
+		{
+			name:       "should_error_for_missing_header",
+			location:   CallbackIDLocation{Source: CallbackIDSourceHeader, Name: "X-Callback-ID"},
+			header:     http.Header{},
+			wantErrMsg: "header X-Callback-ID: not found",
+		},
+		{
+			name:       "should_error_for_missing_body_field",
+			location:   CallbackIDLocation{Source: CallbackIDSourceBody, Name: "data.id"},
+			body:       `{"data":{}}`,
+			wantErrMsg: "field data.id: not found",
+		},
+		{
+			name:       "should_error_for_non_string_id",
+			location:   CallbackIDLocation{Source: CallbackIDSourceBody, Name: "id"},
+			body:       `{"id":123}`,
+			wantErrMsg: "field id: required type is string but got float64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.location.Extract(tt.header, []byte(tt.body))
+			if tt.wantErrMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInjectCallbackIDCreating(t *testing.T) {
+	r := M{"data": map[string]interface{}{"name": "daniel"}}
+
+	err := InjectCallbackIDCreating("data.ref.caller", "123-564", r)
+	require.NoError(t, err)
+	require.Equal(t, M{
+		"data": map[string]interface{}{
+			"name": "daniel",
+			"ref": map[string]interface{}{
+				"caller": map[string]interface{}{CallbackIDFieldName: "123-564"},
+			},
+		},
+	}, r)
+
+	err = InjectCallbackIDCreating("data.name", "123-564", r)
+	require.Error(t, err)
+	require.Equal(t, "the field name, is not an object in the request body", err.Error())
+}
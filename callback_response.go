@@ -0,0 +1,63 @@
+package immune
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CallbackResponse describes how the callback server responds to the
+// callbacks of a test case, it is used to simulate failing and slow receivers
+type CallbackResponse struct {
+	StatusCode int  `json:"status_code"`
+	DelayMS    uint `json:"delay_ms"`
+	// Drop closes the connection without writing a response
+	Drop bool `json:"drop"`
+	// Script holds the responses for successive attempts e.g. [500, 500, 200],
+	// the last step is repeated once the script is exhausted
+	Script []CallbackResponseStep `json:"script"`
+}
+
+// CallbackResponseStep is the response to a single callback attempt
+type CallbackResponseStep struct {
+	StatusCode int  `json:"status_code"`
+	DelayMS    uint `json:"delay_ms"`
+	Drop       bool `json:"drop"`
+}
+
+// UnmarshalJSON allows a step to be written as just its status code
+func (c *CallbackResponseStep) UnmarshalJSON(b []byte) error {
+	var statusCode int
+	if err := json.Unmarshal(b, &statusCode); err == nil {
+		*c = CallbackResponseStep{StatusCode: statusCode}
+		return nil
+	}
+
+	type step CallbackResponseStep // prevents infinite recursion
+	return json.Unmarshal(b, (*step)(c))
+}
+
+// Step returns the response for the given attempt, attempts start from 1.
+// A nil CallbackResponse always responds with 200 OK.
+func (c *CallbackResponse) Step(attempt int) CallbackResponseStep {
+	if c == nil {
+		return CallbackResponseStep{StatusCode: http.StatusOK}
+	}
+
+	step := CallbackResponseStep{StatusCode: c.StatusCode, DelayMS: c.DelayMS, Drop: c.Drop}
+	if len(c.Script) > 0 {
+		ix := attempt - 1
+		if ix >= len(c.Script) {
+			ix = len(c.Script) - 1
+		}
+		if ix < 0 {
+			ix = 0
+		}
+		step = c.Script[ix]
+	}
+
+	if step.StatusCode == 0 {
+		step.StatusCode = http.StatusOK
+	}
+
+	return step
+}
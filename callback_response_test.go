@@ -0,0 +1,27 @@
+package immune
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackResponse_Step(t *testing.T) {
+	var nilResponse *CallbackResponse
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusOK}, nilResponse.Step(1))
+
+	resp := &CallbackResponse{StatusCode: http.StatusServiceUnavailable, DelayMS: 100}
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusServiceUnavailable, DelayMS: 100}, resp.Step(3))
+
+	resp = &CallbackResponse{}
+	err := json.Unmarshal([]byte(`{"script": [500, {"status_code": 502, "delay_ms": 200}, {"drop": true}, 200]}`), resp)
+	require.NoError(t, err)
+
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusInternalServerError}, resp.Step(1))
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusBadGateway, DelayMS: 200}, resp.Step(2))
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusOK, Drop: true}, resp.Step(3))
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusOK}, resp.Step(4))
+	require.Equal(t, CallbackResponseStep{StatusCode: http.StatusOK}, resp.Step(10))
+}
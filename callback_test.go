@@ -127,3 +127,21 @@ func TestInjectCallbackID(t *testing.T) {
 		})
 	}
 }
+
+func TestCallbackConfiguration_AllReceivers(t *testing.T) {
+	cfg := &CallbackConfiguration{
+		Port:  5005,
+		Route: "/cb",
+		Receivers: []CallbackReceiver{
+			{Name: "secondary", Route: "/secondary"},
+			{Name: "other-port", Port: 5006, Route: "/cb"},
+		},
+	}
+
+	got := cfg.AllReceivers()
+	require.Equal(t, []CallbackReceiver{
+		{Name: DefaultReceiverName, Port: 5005, Route: "/cb"},
+		{Name: "secondary", Port: 5005, Route: "/secondary"},
+		{Name: "other-port", Port: 5006, Route: "/cb"},
+	}, got)
+}
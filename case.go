@@ -25,9 +25,117 @@ type TestCase struct {
 	ResponseBody bool     `json:"response_body"`
 	Callback     Callback `json:"callback"`
 	RequestBody  M        `json:"request_body"`
+
+	// BodyType determines how the request body is encoded, it defaults to json
+	BodyType BodyType `json:"body_type"`
+	// RawBody is sent as is for raw & xml bodies, after variable substitution
+	RawBody string `json:"raw_body"`
+	// BodyFile is the path to a file whose content is used as the raw body, a relative
+	// path is resolved from the directory of the config file defining the test case
+	BodyFile string `json:"body_file"`
+	// Files are the file parts of a multipart body
+	Files []File `json:"files"`
+
+	// ResponseHeaders are assertions made on the response headers
+	ResponseHeaders []HeaderAssertion `json:"response_headers"`
+	// ResponseContentType is the expected media type of the response, parameters like charset are ignored
+	ResponseContentType string `json:"response_content_type"`
+	// MaxResponseBodySize is the maximum allowed size of the response body in bytes
+	MaxResponseBodySize int `json:"max_response_body_size"`
+	// StoreResponseHeaders maps variable names to the response headers whose values they store
+	StoreResponseHeaders S `json:"store_response_headers"`
+
+	// MaxDurationMS is the time budget for the response in milliseconds, 0 means no budget
+	MaxDurationMS uint `json:"max_duration_ms"`
+
+	// Repeat is the number of times the request is sent, each time with a new callback id.
+	// The callback count applies to each of them
+	Repeat uint `json:"repeat"`
+
+	// Tags label the test case for selection, e.g smoke
+	Tags []string `json:"tags"`
+	// Skip excludes the test case from runs, SkipReason says why in the report
+	Skip       bool   `json:"skip"`
+	SkipReason string `json:"skip_reason"`
+}
+
+// HasTag reports whether tc is tagged with tag
+func (tc *TestCase) HasTag(tag string) bool {
+	for _, t := range tc.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 type Callback struct {
 	Enabled bool `json:"enabled"`
-	Times   uint `json:"times"`
+	// Times is the exact number of callbacks expected, once they arrive extra
+	// callbacks are awaited for the quiet window
+	Times uint `json:"times"`
+	// Min is the minimum number of callbacks expected, it can't be used with times
+	Min uint `json:"min"`
+	// Max is the maximum number of callbacks expected, it can't be used with times.
+	// With max set, callbacks are awaited until max callback wait seconds elapses
+	Max uint `json:"max"`
+
+	// Expect set to "none" asserts that no callback carrying the test case's
+	// callback id arrives within the quiet window
+	Expect string `json:"expect"`
+	// QuietWindowSeconds is how long to wait for unexpected callbacks after the expected
+	// ones arrived, 1 second by default. Without times, min, max or retry it asserts that no
+	// callback arrives
+	QuietWindowSeconds uint `json:"quiet_window_seconds"`
+
+	// MaxDeliveryMS is the time budget in milliseconds for each callback to arrive,
+	// measured from when the test case request was sent. 0 means no budget
+	MaxDeliveryMS uint `json:"max_delivery_ms"`
+
+	// Response is how the callback server responds to this test case's callbacks
+	Response *CallbackResponse `json:"response"`
+
+	// Retry asserts the number of delivery attempts and their spacing at each receiver,
+	// all attempts carry the same callback id
+	Retry *RetryExpectation `json:"retry"`
+
+	// Ordered asserts that the callbacks of repeated requests arrive in the order they were sent
+	Ordered bool `json:"ordered"`
+	// NoDuplicates asserts that no callback is delivered again to a receiver after a 2xx response,
+	// retries after non-2xx responses are allowed. Duplicates are awaited for the quiet window
+	NoDuplicates bool `json:"no_duplicates"`
+
+	// Receivers asserts that callbacks arrive only at the named receivers, and that
+	// each of them receives at least one callback
+	Receivers []string `json:"receivers"`
+
+	// IDLocation overrides the callback id_location for this test case, in
+	// the format {source}:{name} where source is one of body, header or query
+	IDLocation string `json:"id_location"`
+	// CreateIDLocation creates the objects of a body id location that
+	// are missing from the request body, instead of failing
+	CreateIDLocation bool `json:"create_id_location"`
+}
+
+const CallbackExpectNone = "none"
+
+// ExpectsNone reports whether c asserts that no callback is delivered, either with expect none
+// or with a quiet window and no count or retry expectation
+func (c *Callback) ExpectsNone() bool {
+	return c.Expect == CallbackExpectNone ||
+		(c.Times == 0 && c.Min == 0 && c.Max == 0 && c.Retry == nil && c.QuietWindowSeconds > 0)
+}
+
+// Bounds returns the minimum and maximum number of callbacks expected, a maximum of 0 means unbounded
+func (c *Callback) Bounds() (uint, uint) {
+	if c.Times > 0 {
+		return c.Times, c.Times
+	}
+	return c.Min, c.Max
+}
+
+// Satisfied reports whether got callbacks is enough to stop waiting for more
+func (c *Callback) Satisfied(got uint) bool {
+	lower, _ := c.Bounds()
+	return got >= lower && (c.Times > 0 || c.Max == 0)
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	prefixed "github.com/x-cray/logrus-prefixed-formatter"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// configureLogging sets the format & the level of the logs, format is text or json
+func configureLogging(format, level string) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return errors.Wrap(err, "invalid --log-level")
+	}
+	log.SetLevel(lvl)
+
+	switch format {
+	case logFormatText:
+		log.SetFormatter(&prefixed.TextFormatter{
+			DisableColors:   false,
+			TimestampFormat: "2006-01-02 15:04:05",
+			FullTimestamp:   true,
+			ForceFormatting: true,
+		})
+	case logFormatJSON:
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return errors.Errorf("invalid --log-format %q, it must be %s or %s", format, logFormatText, logFormatJSON)
+	}
+
+	return nil
+}
+
+// fieldsHook adds its fields to every log entry that does not set them
+type fieldsHook log.Fields
+
+func (h fieldsHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h fieldsHook) Fire(entry *log.Entry) error {
+	for key, value := range h {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+	return nil
+}
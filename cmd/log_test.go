@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/frain-dev/immune"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreLogging restores the standard logger once a test has configured it
+func restoreLogging(t *testing.T) {
+	logger := log.StandardLogger()
+	out, formatter, level := logger.Out, logger.Formatter, logger.GetLevel()
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+		logger.ReplaceHooks(make(log.LevelHooks))
+	})
+}
+
+func Test_configureLogging(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		level      string
+		wantLevel  log.Level
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:      "should_configure_text_logs",
+			format:    logFormatText,
+			level:     "info",
+			wantLevel: log.InfoLevel,
+		},
+		{
+			name:      "should_configure_json_logs",
+			format:    logFormatJSON,
+			level:     "debug",
+			wantLevel: log.DebugLevel,
+		},
+		{
+			name:       "should_error_for_invalid_format",
+			format:     "xml",
+			level:      "info",
+			wantErr:    true,
+			wantErrMsg: `invalid --log-format "xml", it must be text or json`,
+		},
+		{
+			name:       "should_error_for_invalid_level",
+			format:     logFormatText,
+			level:      "loud",
+			wantErr:    true,
+			wantErrMsg: `invalid --log-level: not a valid logrus Level: "loud"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreLogging(t)
+
+			err := configureLogging(tt.format, tt.level)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantLevel, log.GetLevel())
+		})
+	}
+}
+
+func Test_configureLogging_JSONFields(t *testing.T) {
+	restoreLogging(t)
+	require.NoError(t, configureLogging(logFormatJSON, "debug"))
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.AddHook(fieldsHook{immune.LogFieldRunID: "run-1", immune.LogFieldTestCase: "hook"})
+
+	log.WithFields(log.Fields{
+		immune.LogFieldTestCase:  "create_event",
+		immune.LogFieldSetupStep: "setup_app",
+	}).Warn("setup failed")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	ts, ok := entry["time"].(string)
+	require.True(t, ok)
+	_, err := time.Parse(time.RFC3339Nano, ts)
+	require.NoError(t, err)
+	delete(entry, "time")
+
+	// the fields of the entry take precedence over those of the hook
+	require.Equal(t, map[string]interface{}{
+		"level":                  "warning",
+		"msg":                    "setup failed",
+		immune.LogFieldRunID:     "run-1",
+		immune.LogFieldTestCase:  "create_event",
+		immune.LogFieldSetupStep: "setup_app",
+	}, entry)
+}
+
+func Test_fieldsHook(t *testing.T) {
+	tests := []struct {
+		name       string
+		hook       fieldsHook
+		data       log.Fields
+		wantFields log.Fields
+	}{
+		{
+			name:       "should_add_fields",
+			hook:       fieldsHook{immune.LogFieldRunID: "run-1"},
+			data:       log.Fields{immune.LogFieldTestCase: "a"},
+			wantFields: log.Fields{immune.LogFieldRunID: "run-1", immune.LogFieldTestCase: "a"},
+		},
+		{
+			name:       "should_keep_fields_set_by_entry",
+			hook:       fieldsHook{immune.LogFieldRunID: "run-1", immune.LogFieldTestCase: "hook"},
+			data:       log.Fields{immune.LogFieldTestCase: "a"},
+			wantFields: log.Fields{immune.LogFieldRunID: "run-1", immune.LogFieldTestCase: "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := log.NewEntry(log.StandardLogger()).WithFields(tt.data)
+
+			require.NoError(t, tt.hook.Fire(entry))
+			require.Equal(t, tt.wantFields, entry.Data)
+			require.Equal(t, log.AllLevels, tt.hook.Levels())
+		})
+	}
+}
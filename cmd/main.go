@@ -2,12 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/callback"
+	"github.com/frain-dev/immune/exec"
+	"github.com/frain-dev/immune/mockconvoy"
 	"github.com/frain-dev/immune/system"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 )
 
 func main() {
@@ -16,52 +26,125 @@ func main() {
 		log.WithError(err).Fatal("failed to set env")
 	}
 
-	log.SetLevel(log.InfoLevel)
-
-	log.SetFormatter(&prefixed.TextFormatter{
-		DisableColors:   false,
-		TimestampFormat: "2006-01-02 15:04:05",
-		FullTimestamp:   true,
-		ForceFormatting: true,
-	})
+	err = configureLogging(logFormatText, log.InfoLevel.String())
+	if err != nil {
+		log.WithError(err).Fatal("failed to configure logging")
+	}
 
+	var logFormat, logLevel string
 	cmd := &cobra.Command{
 		Use:   "Immune",
 		Short: "API Testing tool",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return configureLogging(logFormat, logLevel)
+		},
 	}
 
-	var configFile string
-	cmd.PersistentFlags().StringVar(&configFile, "config", "./immune.json", "Configuration file for immune")
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "Format of the logs, text or json")
+	cmd.PersistentFlags().StringVar(&logLevel, "log-level", log.InfoLevel.String(), "Level of the logs e.g debug, info, warn or error")
+
+	var configFiles []string
+	cmd.PersistentFlags().StringArrayVar(&configFiles, "config", []string{"./immune.json"},
+		"Configuration file for immune, json, yaml or toml. It can be repeated, later files take precedence")
+
+	var opts system.Options
+	cmd.PersistentFlags().StringVar(&opts.Profile, "profile", "", "Profile of the configuration to overlay e.g staging")
+	cmd.PersistentFlags().StringArrayVar(&opts.Set, "set", nil,
+		"Override a configuration field e.g callback.port=8080, it can be repeated and takes precedence over everything else")
 
 	cmd.AddCommand(addRunCommand())
+	cmd.AddCommand(addCallbacksCommand())
+	cmd.AddCommand(addReceiverCommand())
+	cmd.AddCommand(addMockConvoyCommand())
+	cmd.AddCommand(addValidateCommand())
+	cmd.AddCommand(addPlanCommand())
+	cmd.AddCommand(addSchemaCommand())
 
 	if err := cmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// loadSystem loads the system from the configuration flags
+func loadSystem(cmd *cobra.Command) (*system.System, error) {
+	cfgPaths, err := cmd.Flags().GetStringArray("config")
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return nil, err
+	}
+
+	sys, err := system.NewSystem(system.Options{Profile: profile, Set: set}, cfgPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.AddHook(sys.Redactor.Hook())
+	return sys, nil
+}
+
+// addSelectionFlags adds the flags selecting the test cases of cmd to sel
+func addSelectionFlags(cmd *cobra.Command, sel *system.Selection) {
+	cmd.Flags().StringVar(&sel.Run, "run", "", "Only the test cases whose names match this regular expression")
+	cmd.Flags().StringSliceVar(&sel.Tags, "tags", nil, "Only the test cases with at least one of these tags e.g smoke,payments")
+	cmd.Flags().StringSliceVar(&sel.ExcludeTags, "exclude-tags", nil, "Skip the test cases with any of these tags")
+	cmd.Flags().StringSliceVar(&sel.Skip, "skip", nil, "Names of the test cases to skip")
+}
+
 func addRunCommand() *cobra.Command {
+	var trace bool
+	var traceDir string
+	var sel system.Selection
+
 	cmd := &cobra.Command{
 		Use:     "run",
 		Aliases: []string{"r"},
 		Short:   "Run the Immune tests",
 		Run: func(cmd *cobra.Command, args []string) {
-			err := run(cmd)
+			err := run(cmd, sel, trace, traceDir)
 			if err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVar(&trace, "trace", false, "Print every request, response and callback, with secrets redacted")
+	cmd.Flags().BoolVarP(&trace, "verbose", "v", false, "Alias of --trace")
+	cmd.Flags().StringVar(&traceDir, "trace-dir", "", "Directory to write a trace file per test case to, it works with or without --trace")
+	addSelectionFlags(cmd, &sel)
 	return cmd
 }
 
-func run(cmd *cobra.Command) error {
-	cfgPath, err := cmd.Flags().GetString("config")
+func run(cmd *cobra.Command, sel system.Selection, trace bool, traceDir string) error {
+	// every log of the run carries its id, to tell the runs apart in aggregated logs
+	log.AddHook(fieldsHook{immune.LogFieldRunID: uuid.New().String()})
+
+	sys, err := loadSystem(cmd)
 	if err != nil {
 		return err
 	}
 
-	sys, err := system.NewSystem(cfgPath)
+	if trace || traceDir != "" {
+		var out io.Writer
+		if trace {
+			out = os.Stderr
+		}
+
+		sys.Tracer, err = exec.NewTracer(out, traceDir, sys.Redactor)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = sys.Select(sel)
 	if err != nil {
 		return err
 	}
@@ -79,3 +162,204 @@ func run(cmd *cobra.Command) error {
 	log.Infof("all tests passed")
 	return nil
 }
+
+func addValidateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Lint the configuration without sending any request",
+		Run: func(cmd *cobra.Command, args []string) {
+			sys, err := loadSystem(cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			problems := sys.Validate()
+			for _, p := range problems {
+				log.Error(p)
+			}
+
+			if len(problems) > 0 {
+				log.Fatalf("configuration has %d problem(s)", len(problems))
+			}
+			log.Info("configuration is valid")
+		},
+	}
+	return cmd
+}
+
+func addPlanCommand() *cobra.Command {
+	var sel system.Selection
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print the execution order of the test cases without sending any request",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := plan(cmd, sel)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	addSelectionFlags(cmd, &sel)
+	return cmd
+}
+
+func plan(cmd *cobra.Command, sel system.Selection) error {
+	sys, err := loadSystem(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = sys.Select(sel)
+	if err != nil {
+		return err
+	}
+
+	err = sys.Clean()
+	if err != nil {
+		return err
+	}
+
+	p, err := sys.Plan()
+	if err != nil {
+		return err
+	}
+
+	return p.Write(os.Stdout)
+}
+
+func addSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			err := enc.Encode(system.Schema())
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	return cmd
+}
+
+func addCallbacksCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "callbacks",
+		Short: "Work with the callbacks recorded by the callback server",
+	}
+
+	cmd.AddCommand(addReplayCommand())
+	return cmd
+}
+
+func addReplayCommand() *cobra.Command {
+	var to string
+	var logFile string
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Re-send the recorded callbacks to another receiver",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := replay(cmd, logFile, to)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "URL of the receiver the callbacks are sent to")
+	cmd.Flags().StringVar(&logFile, "file", "", "Callback log file, defaults to the log_file in the callback configuration")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func replay(cmd *cobra.Command, logFile string, to string) error {
+	if logFile == "" {
+		sys, err := loadSystem(cmd)
+		if err != nil {
+			return err
+		}
+
+		logFile = sys.Callback.LogFile
+		if logFile == "" {
+			return errors.New("no callback log file: set --file or callback.log_file in the configuration")
+		}
+	}
+
+	count, err := callback.Replay(context.Background(), http.DefaultClient, logFile, to)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("replayed %d callbacks to %s", count, to)
+	return nil
+}
+
+func addReceiverCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "receiver",
+		Short: "Run only the callback server, for a test driver on another machine",
+		Long: "Runs the callback server of the configuration, streaming the callbacks it receives to " +
+			"the test driver whose callback.remote_url points at it",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := receiver(cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	return cmd
+}
+
+func receiver(cmd *cobra.Command) error {
+	sys, err := loadSystem(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = sys.CleanCallback()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return callback.ServeRemote(ctx, &sys.Callback, sys.Redactor)
+}
+
+func addMockConvoyCommand() *cobra.Command {
+	cfg := mockconvoy.Config{}
+
+	cmd := &cobra.Command{
+		Use:   "mock-convoy",
+		Short: "Run a fake Convoy server, for developing test suites without Convoy or MongoDB",
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfg.FailureRate < 0 || cfg.FailureRate > 1 {
+				log.Fatal("failure-rate must be between 0 and 1")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			err := mockconvoy.New(cfg).ListenAndServe(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().UintVar(&cfg.Port, "port", 5005, "Port to listen on")
+	cmd.Flags().StringVar(&cfg.BasePath, "base-path", mockconvoy.DefaultBasePath, "Path the API is served under")
+	cmd.Flags().UintVar(&cfg.LatencyMS, "latency-ms", 0, "Delay before every delivery attempt, in milliseconds")
+	cmd.Flags().Float64Var(&cfg.FailureRate, "failure-rate", 0, "Probability between 0 and 1 that a delivery attempt fails")
+	cmd.Flags().UintVar(&cfg.RetryIntervalMS, "retry-interval-ms", 0, "Overrides the retry interval of every group, in milliseconds")
+	cmd.Flags().StringVar(&cfg.SignatureHeader, "signature-header", mockconvoy.DefaultSignatureHeader, "Signature header of groups without a signature config")
+	cmd.Flags().StringVar(&cfg.SignatureHash, "signature-hash", mockconvoy.DefaultSignatureHash, "Signature hash of groups without a signature config, SHA256 or SHA512")
+	cmd.Flags().Int64Var(&cfg.Seed, "seed", 0, "Seed of the failure rate, 0 uses the current time")
+	return cmd
+}
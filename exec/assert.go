@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"mime"
+	"net/http"
+	"regexp"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// assertResponse checks the response's content type, size and headers
+// against the assertions declared in tc
+func assertResponse(tc *immune.TestCase, resp *response) error {
+	if tc.ResponseContentType != "" {
+		err := assertContentType(tc.ResponseContentType, resp.header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if tc.MaxResponseBodySize > 0 && len(resp.buf) > tc.MaxResponseBodySize {
+		return errors.Errorf("wants response body size of at most %d bytes but got %d bytes", tc.MaxResponseBodySize, len(resp.buf))
+	}
+
+	for i := range tc.ResponseHeaders {
+		err := assertHeader(&tc.ResponseHeaders[i], resp.header)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func assertContentType(want, got string) error {
+	if got == "" {
+		return errors.Errorf("wants content type %s but got no content type", want)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(got)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse response content type '%s'", got)
+	}
+
+	if mediaType != want {
+		return errors.Errorf("wants content type %s but got content type %s", want, mediaType)
+	}
+
+	return nil
+}
+
+func assertHeader(ha *immune.HeaderAssertion, header http.Header) error {
+	values, exists := header[http.CanonicalHeaderKey(ha.Name)]
+	if ha.Absent {
+		if exists {
+			return errors.Errorf("wants header %s to be absent but got header %s: '%s'", ha.Name, ha.Name, header.Get(ha.Name))
+		}
+		return nil
+	}
+
+	if !exists {
+		return errors.Errorf("wants header %s but got no header %s", ha.Name, ha.Name)
+	}
+
+	got := values[0]
+	if ha.Value != "" && got != ha.Value {
+		return errors.Errorf("wants header %s with value '%s' but got value '%s'", ha.Name, ha.Value, got)
+	}
+
+	if ha.Regex != "" {
+		re, err := regexp.Compile(ha.Regex)
+		if err != nil {
+			return errors.Wrapf(err, "invalid regex for header %s", ha.Name)
+		}
+
+		if !re.MatchString(got) {
+			return errors.Errorf("wants header %s to match '%s' but got value '%s'", ha.Name, ha.Regex, got)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package exec
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_assertResponse(t *testing.T) {
+	resp := &response{
+		statusCode: http.StatusCreated,
+		buf:        []byte(`{"status":true}`),
+		header: http.Header{
+			"Content-Type":  []string{"application/json; charset=utf-8"},
+			"Location":      []string{"/users/1234"},
+			"X-Request-Id":  []string{"abc-123"},
+			"Cache-Control": []string{"no-cache"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		tc         *immune.TestCase
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_pass_all_assertions",
+			tc: &immune.TestCase{
+				ResponseContentType: "application/json",
+				MaxResponseBodySize: 100,
+				ResponseHeaders: []immune.HeaderAssertion{
+					{Name: "location", Regex: "^/users/[0-9]+$"},
+					{Name: "Cache-Control", Value: "no-cache"},
+					{Name: "X-Request-Id"},
+					{Name: "Set-Cookie", Absent: true},
+				},
+			},
+		},
+		{
+			name:       "should_error_for_wrong_content_type",
+			tc:         &immune.TestCase{ResponseContentType: "application/xml"},
+			wantErr:    true,
+			wantErrMsg: "wants content type application/xml but got content type application/json",
+		},
+		{
+			name:       "should_error_for_body_too_large",
+			tc:         &immune.TestCase{MaxResponseBodySize: 10},
+			wantErr:    true,
+			wantErrMsg: "wants response body size of at most 10 bytes but got 15 bytes",
+		},
+		{
+			name: "should_error_for_missing_header",
+			tc: &immune.TestCase{
+				ResponseHeaders: []immune.HeaderAssertion{{Name: "ETag"}},
+			},
+			wantErr:    true,
+			wantErrMsg: "wants header ETag but got no header ETag",
+		},
+		{
+			name: "should_error_for_present_header",
+			tc: &immune.TestCase{
+				ResponseHeaders: []immune.HeaderAssertion{{Name: "Location", Absent: true}},
+			},
+			wantErr:    true,
+			wantErrMsg: "wants header Location to be absent but got header Location: '/users/1234'",
+		},
+		{
+			name: "should_error_for_wrong_header_value",
+			tc: &immune.TestCase{
+				ResponseHeaders: []immune.HeaderAssertion{{Name: "Cache-Control", Value: "no-store"}},
+			},
+			wantErr:    true,
+			wantErrMsg: "wants header Cache-Control with value 'no-store' but got value 'no-cache'",
+		},
+		{
+			name: "should_error_for_header_regex_mismatch",
+			tc: &immune.TestCase{
+				ResponseHeaders: []immune.HeaderAssertion{{Name: "Location", Regex: "^/groups/"}},
+			},
+			wantErr:    true,
+			wantErrMsg: "wants header Location to match '^/groups/' but got value '/users/1234'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := assertResponse(tt.tc, resp)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// encode writes the request body in the format required by its body type, it returns
+// the encoded body and the content type to be sent with it
+func (r *request) encode() (*bytes.Buffer, string, error) {
+	bb := &bytes.Buffer{}
+
+	switch r.bodyType {
+	case immune.BodyTypeForm:
+		values, err := formValues(r.body)
+		if err != nil {
+			return nil, "", err
+		}
+		bb.WriteString(values.Encode())
+		return bb, r.bodyType.ContentType(), nil
+	case immune.BodyTypeMultipart:
+		contentType, err := r.writeMultipart(bb)
+		if err != nil {
+			return nil, "", err
+		}
+		return bb, contentType, nil
+	case immune.BodyTypeRaw, immune.BodyTypeXML:
+		bb.WriteString(r.rawBody)
+		return bb, r.bodyType.ContentType(), nil
+	default:
+		if r.rawBody != "" { // json loaded from a body file is sent as is
+			bb.WriteString(r.rawBody)
+		} else if r.body != nil {
+			rb, err := json.Marshal(r.body)
+			if err != nil {
+				return nil, "", errors.Wrap(err, "failed to marshal request body")
+			}
+			bb.Write(rb)
+		}
+		return bb, r.contentType, nil
+	}
+}
+
+// writeMultipart writes the request body fields and files as parts of a multipart
+// body into w, it returns the content type containing the multipart boundary
+func (r *request) writeMultipart(w io.Writer) (string, error) {
+	mw := multipart.NewWriter(w)
+
+	values, err := formValues(r.body)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range sortedKeys(values) {
+		for _, v := range values[key] {
+			err = mw.WriteField(key, v)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to write multipart field %s", key)
+			}
+		}
+	}
+
+	for _, file := range r.files {
+		err = writeFilePart(mw, file)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to close multipart writer")
+	}
+
+	return mw.FormDataContentType(), nil
+}
+
+func writeFilePart(mw *multipart.Writer, file immune.File) error {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open multipart file %s", file.Path)
+	}
+	defer f.Close()
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, file.FieldName, filepath.Base(file.Path)))
+	h.Set("Content-Type", contentType)
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create multipart file part %s", file.FieldName)
+	}
+
+	_, err = io.Copy(part, f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write multipart file %s", file.Path)
+	}
+
+	return nil
+}
+
+// formValues converts the request body into url values, arrays are
+// sent as repeated values of the same key
+func formValues(m immune.M) (url.Values, error) {
+	values := url.Values{}
+	for k, v := range m {
+		if arr, ok := v.([]interface{}); ok {
+			for _, item := range arr {
+				str, err := formatFormValue(item)
+				if err != nil {
+					return nil, errors.Wrapf(err, "field %s", k)
+				}
+				values.Add(k, str)
+			}
+			continue
+		}
+
+		str, err := formatFormValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", k)
+		}
+		values.Set(k, str)
+	}
+	return values, nil
+}
+
+func formatFormValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	case map[string]interface{}:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
+
+func sortedKeys(values url.Values) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,137 @@
+package exec
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_request_encode(t *testing.T) {
+	tests := []struct {
+		name            string
+		r               *request
+		wantBody        string
+		wantContentType string
+	}{
+		{
+			name: "should_encode_json_body",
+			r: &request{
+				contentType: "application/json",
+				body:        immune.M{"name": "daniel"},
+			},
+			wantBody:        `{"name":"daniel"}`,
+			wantContentType: "application/json",
+		},
+		{
+			name: "should_encode_json_body_file",
+			r: &request{
+				contentType: "application/json",
+				rawBody:     `{"name": "daniel"}`,
+			},
+			wantBody:        `{"name": "daniel"}`,
+			wantContentType: "application/json",
+		},
+		{
+			name: "should_encode_form_body",
+			r: &request{
+				bodyType: immune.BodyTypeForm,
+				body: immune.M{
+					"name":   "daniel",
+					"phone":  float64(23453530833),
+					"groups": []interface{}{"abc", "def"},
+				},
+			},
+			wantBody:        "groups=abc&groups=def&name=daniel&phone=23453530833",
+			wantContentType: "application/x-www-form-urlencoded",
+		},
+		{
+			name: "should_encode_raw_body",
+			r: &request{
+				bodyType: immune.BodyTypeRaw,
+				rawBody:  "hello daniel",
+			},
+			wantBody:        "hello daniel",
+			wantContentType: "text/plain",
+		},
+		{
+			name: "should_encode_xml_body",
+			r: &request{
+				bodyType: immune.BodyTypeXML,
+				rawBody:  "<user><name>daniel</name></user>",
+			},
+			wantBody:        "<user><name>daniel</name></user>",
+			wantContentType: "application/xml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bb, contentType, err := tt.r.encode()
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, bb.String())
+			require.Equal(t, tt.wantContentType, contentType)
+		})
+	}
+}
+
+func Test_request_encode_multipart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "avatar.txt")
+	err := ioutil.WriteFile(path, []byte("avatar-content"), os.ModePerm)
+	require.NoError(t, err)
+
+	r := &request{
+		bodyType: immune.BodyTypeMultipart,
+		body:     immune.M{"name": "daniel"},
+		files: []immune.File{
+			{FieldName: "avatar", Path: path, ContentType: "text/plain"},
+		},
+	}
+
+	bb, contentType, err := r.encode()
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	require.Equal(t, "multipart/form-data", mediaType)
+
+	form, err := multipart.NewReader(bb, params["boundary"]).ReadForm(1024)
+	require.NoError(t, err)
+	require.Equal(t, []string{"daniel"}, form.Value["name"])
+	require.Len(t, form.File["avatar"], 1)
+
+	fh := form.File["avatar"][0]
+	require.Equal(t, "avatar.txt", fh.Filename)
+	require.Equal(t, "text/plain", fh.Header.Get("Content-Type"))
+
+	f, err := fh.Open()
+	require.NoError(t, err)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "avatar-content", string(content))
+}
+
+func Test_request_replaceVariables(t *testing.T) {
+	vm := &immune.VariableMap{
+		VariableToValue: immune.M{
+			"app_id": "123-454-655",
+			"amount": 2000,
+		},
+	}
+	r := &request{vars: map[string]string{immune.CallbackIDFieldName: "12345", "amount": "1"}}
+
+	got, err := r.replaceVariables(`<event app="{app_id}" amount="{amount}" id="{immune_callback_id}">{"raw": true}</event>`, vm)
+	require.NoError(t, err)
+	require.Equal(t, `<event app="123-454-655" amount="1" id="12345">{"raw": true}</event>`, got)
+
+	_, err = r.replaceVariables("app={group_id}", vm)
+	require.Error(t, err)
+	require.Equal(t, "variable group_id does not exist in variable map", err.Error())
+}
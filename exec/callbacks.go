@@ -0,0 +1,396 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// sentEvent is a single request sent by a test case, and the callbacks received for it
+type sentEvent struct {
+	callbackID string
+	sentAt     time.Time
+	signals    []*immune.Signal
+}
+
+// defaultQuietWindow is how long callbacks are awaited after the expected callbacks
+// arrived, for test cases that bound their callbacks but set no quiet window
+const defaultQuietWindow = time.Second
+
+// callbackWait collects the callbacks of the events of a test case
+type callbackWait struct {
+	tc       *immune.TestCase
+	events   []*sentEvent
+	byID     map[string]*sentEvent
+	ids      []string
+	received uint
+}
+
+// waitForCallbacks waits for the callbacks of events until the callback count of tc is satisfied
+// for every event or max callback wait seconds elapses. Test cases expecting an exact number of
+// callbacks, or no duplicates, then keep collecting callbacks for their quiet window so that extra
+// callbacks are caught by the test case they belong to. It errors if the number of callbacks
+// received is outside the bounds of tc's callback count
+func (ex *Executor) waitForCallbacks(tc *immune.TestCase, events []*sentEvent) error {
+	cctx, cancel := context.WithTimeout(context.Background(), time.Duration(ex.maxCallbackWaitSeconds)*time.Second)
+	defer cancel()
+
+	w := &callbackWait{tc: tc, events: events, byID: make(map[string]*sentEvent, len(events))}
+	for _, ev := range events {
+		w.byID[ev.callbackID] = ev
+		w.ids = append(w.ids, ev.callbackID)
+	}
+
+	err := ex.collectCallbacks(cctx, w, func() bool { return allSatisfied(&tc.Callback, events) })
+	if err != nil {
+		return err
+	}
+
+	if allSatisfied(&tc.Callback, events) && (tc.Callback.Times > 0 || tc.Callback.NoDuplicates) {
+		qctx, cancel := context.WithTimeout(context.Background(), ex.quietWindowOf(tc))
+		defer cancel()
+
+		err = ex.collectCallbacks(qctx, w, func() bool { return false })
+		if err != nil {
+			return err
+		}
+	}
+
+	lower, _ := tc.Callback.Bounds()
+	for _, ev := range events {
+		got := uint(len(ev.signals))
+		if got >= lower {
+			continue
+		}
+
+		ex.missing = append(ex.missing, immune.MissingCallback{TestCase: tc.Name, CallbackID: ev.callbackID, Want: lower, Got: got})
+		if err != nil {
+			continue // report every missing callback, but return the first error
+		}
+
+		if tc.Callback.Times > 0 {
+			err = errors.Errorf("test_case %s: %swants %d callbacks but got %d callbacks before max callback wait seconds elapsed", tc.Name, eventPrefix(events, ev), lower, got)
+		} else {
+			err = errors.Errorf("test_case %s: %swants at least %d callbacks but got %d callbacks before max callback wait seconds elapsed", tc.Name, eventPrefix(events, ev), lower, got)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, ev := range events {
+		err = verifyReceivers(tc.Callback.Receivers, ev.signals)
+		if err != nil {
+			return errors.Errorf("test_case %s: %s%v", tc.Name, eventPrefix(events, ev), err)
+		}
+	}
+
+	// duplicates are extra callbacks too, they are reported as such first
+	if tc.Callback.NoDuplicates {
+		for _, ev := range events {
+			err = verifyNoDuplicates(tc, ex.s.History(ev.callbackID))
+			if err != nil {
+				return errors.Errorf("test_case %s: %s%v", tc.Name, eventPrefix(events, ev), err)
+			}
+		}
+	}
+
+	// the extra attempts of a retry expectation are reported by its schedule
+	if tc.Callback.Retry != nil {
+		for _, ev := range events {
+			receivers := byReceiver(ev.signals)
+			for _, attempts := range receivers {
+				err = verifyRetrySchedule(tc.Callback.Retry, attempts)
+				if err != nil && len(receivers) > 1 {
+					err = errors.Wrapf(err, "receiver %s", attempts[0].Receiver)
+				}
+				if err != nil {
+					return errors.Errorf("test_case %s: %sretry schedule: %v", tc.Name, eventPrefix(events, ev), err)
+				}
+			}
+		}
+	} else if tc.Callback.Times > 0 {
+		for _, ev := range events {
+			if got := uint(len(ev.signals)); got > tc.Callback.Times {
+				return errors.Errorf("test_case %s: %swants %d callbacks but got %d callbacks", tc.Name, eventPrefix(events, ev), tc.Callback.Times, got)
+			}
+		}
+	}
+
+	if tc.Callback.Ordered {
+		err = ex.verifyOrder(events)
+		if err != nil {
+			return errors.Wrapf(err, "test_case %s", tc.Name)
+		}
+	}
+
+	return nil
+}
+
+// collectCallbacks receives the callbacks of w until done returns true or ctx is done. Callbacks
+// of other test cases, or stale callbacks of earlier ones, are ignored
+func (ex *Executor) collectCallbacks(ctx context.Context, w *callbackWait, done func() bool) error {
+	tc := w.tc
+	_, upper := tc.Callback.Bounds()
+	signalChan := make(chan *immune.Signal, 1)
+
+	for !done() {
+		ex.s.ReceiveCallback(ctx, signalChan)
+
+		var sig *immune.Signal
+		select {
+		case sig = <-signalChan:
+		default:
+		}
+
+		if sig == nil { // ctx is done
+			return nil
+		}
+		ex.tracer.callback(sig)
+
+		if sig.HasError() {
+			return errors.Errorf("test_case %s: callback error: %s", tc.Name, sig.Error())
+		}
+
+		ev, ok := w.byID[sig.ImmuneCallBackID]
+		if !ok {
+			callbackLog(tc, sig).Warnf("test_case %s: ignoring callback %s, it is not one of '%s'", tc.Name, sig.ImmuneCallBackID, strings.Join(w.ids, "', '"))
+			continue
+		}
+
+		w.received++
+		if tc.Callback.MaxDeliveryMS > 0 {
+			delivery := deliveryDuration(ev.sentAt, sig)
+			if delivery > msToDuration(tc.Callback.MaxDeliveryMS) {
+				return errors.Errorf("test_case %s: wants callback %d delivered within %dms but took %dms", tc.Name, w.received, tc.Callback.MaxDeliveryMS, delivery.Milliseconds())
+			}
+		}
+
+		if len(tc.Callback.Receivers) > 0 && !contains(tc.Callback.Receivers, sig.Receiver) {
+			return errors.Errorf("test_case %s: callback %d arrived at receiver '%s' but wants receivers '%s'", tc.Name, w.received, sig.Receiver, strings.Join(tc.Callback.Receivers, "', '"))
+		}
+
+		ev.signals = append(ev.signals, sig)
+		callbackLog(tc, sig).Infof("callback %d for test_case %s received", w.received, tc.Name)
+
+		// exact counts are checked once the quiet window elapses, with every extra callback collected
+		if tc.Callback.Times == 0 && upper > 0 && uint(len(ev.signals)) > upper {
+			return errors.Errorf("test_case %s: %swants at most %d callbacks but got %d callbacks", tc.Name, eventPrefix(w.events, ev), upper, len(ev.signals))
+		}
+	}
+
+	return nil
+}
+
+// quietWindowOf returns how long the callbacks of tc are awaited after the expected ones arrived
+func (ex *Executor) quietWindowOf(tc *immune.TestCase) time.Duration {
+	if tc.Callback.QuietWindowSeconds > 0 {
+		return time.Duration(tc.Callback.QuietWindowSeconds) * time.Second
+	}
+	return ex.quietWindow
+}
+
+func allSatisfied(cb *immune.Callback, events []*sentEvent) bool {
+	for _, ev := range events {
+		if !cb.Satisfied(uint(len(ev.signals))) {
+			return false
+		}
+	}
+	return true
+}
+
+// eventPrefix identifies ev in error messages when a test case sends more than one event
+func eventPrefix(events []*sentEvent, ev *sentEvent) string {
+	if len(events) < 2 {
+		return ""
+	}
+
+	for i := range events {
+		if events[i] == ev {
+			return fmt.Sprintf("event %d: ", i+1)
+		}
+	}
+	return ""
+}
+
+// verifyReceivers checks that each of receivers got at least one of signals
+func verifyReceivers(receivers []string, signals []*immune.Signal) error {
+	for _, name := range receivers {
+		received := false
+		for _, sig := range signals {
+			if sig.Receiver == name {
+				received = true
+				break
+			}
+		}
+
+		if !received {
+			return errors.Errorf("wants a callback at receiver '%s' but got none", name)
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyNoDuplicates checks the arrival history of a callback id of tc at each receiver, any attempt
+// that arrives after a 2xx response is a duplicate delivery, attempts after non-2xx responses are
+// retries. Deliveries of the same event to other receivers are neither
+func verifyNoDuplicates(tc *immune.TestCase, history []immune.Signal) error {
+	signals := make([]*immune.Signal, 0, len(history))
+	for i := range history {
+		signals = append(signals, &history[i])
+	}
+
+	for _, attempts := range byReceiver(signals) {
+		retries := 0
+		delivered := false
+		for i, sig := range attempts {
+			if delivered {
+				return errors.Errorf("%s: duplicate delivery: attempt %d arrived after a 2xx response", describeDelivery(sig), sig.Attempt)
+			}
+
+			if i > 0 {
+				retries++
+			}
+
+			if isSuccess(sig.StatusCode) {
+				delivered = true
+			}
+		}
+
+		if retries > 0 {
+			last := attempts[len(attempts)-1]
+			callbackLog(tc, last).Infof("%s was retried %d time(s) after non-2xx responses", describeDelivery(last), retries)
+		}
+	}
+
+	return nil
+}
+
+// byReceiver groups signals by the receiver they arrived at, in order of first arrival. The
+// attempts of a callback id are counted per receiver, see immune.Signal.Attempt
+func byReceiver(signals []*immune.Signal) [][]*immune.Signal {
+	var groups [][]*immune.Signal
+	index := map[string]int{}
+	for _, sig := range signals {
+		i, ok := index[sig.Receiver]
+		if !ok {
+			i = len(groups)
+			index[sig.Receiver] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], sig)
+	}
+	return groups
+}
+
+// describeDelivery names the callback id of sig, and its receiver if it has one
+func describeDelivery(sig *immune.Signal) string {
+	if sig.Receiver == "" {
+		return fmt.Sprintf("callback %s", sig.ImmuneCallBackID)
+	}
+	return fmt.Sprintf("callback %s at receiver %s", sig.ImmuneCallBackID, sig.Receiver)
+}
+
+// verifyOrder checks that the first delivery of every event arrived in the order the events were sent
+func (ex *Executor) verifyOrder(events []*sentEvent) error {
+	var prev time.Time
+	for i, ev := range events {
+		history := ex.s.History(ev.callbackID)
+		if len(history) == 0 {
+			return errors.Errorf("event %d: no callback received", i+1)
+		}
+
+		first := history[0].ReceivedAt
+		if i > 0 && first.Before(prev) {
+			return errors.Errorf("callbacks out of order: event %d arrived before event %d", i+1, i)
+		}
+		prev = first
+	}
+
+	return nil
+}
+
+// MissingCallbacks returns the callbacks that did not arrive before max callback wait
+// seconds elapsed, for all the test cases executed so far
+func (ex *Executor) MissingCallbacks() []immune.MissingCallback {
+	return ex.missing
+}
+
+// waitForNoCallback waits out the quiet window of tc, it errors if a callback
+// for any of events arrives before the window elapses
+func (ex *Executor) waitForNoCallback(tc *immune.TestCase, events []*sentEvent) error {
+	window := tc.Callback.QuietWindowSeconds
+	if window == 0 {
+		window = ex.maxCallbackWaitSeconds
+	}
+
+	qctx, cancel := context.WithTimeout(context.Background(), time.Duration(window)*time.Second)
+	defer cancel()
+
+	byID := make(map[string]*sentEvent, len(events))
+	for _, ev := range events {
+		byID[ev.callbackID] = ev
+	}
+
+	signalChan := make(chan *immune.Signal, 1)
+	for {
+		ex.s.ReceiveCallback(qctx, signalChan)
+
+		select {
+		case sig := <-signalChan:
+			ex.tracer.callback(sig)
+			if sig.HasError() {
+				return errors.Errorf("test_case %s: callback error: %s", tc.Name, sig.Error())
+			}
+			if ev, ok := byID[sig.ImmuneCallBackID]; ok {
+				return errors.Errorf("test_case %s: wants no callback but got callback %s after %dms", tc.Name, ev.callbackID, deliveryDuration(ev.sentAt, sig).Milliseconds())
+			}
+			callbackLog(tc, sig).Infof("test_case %s: ignoring callback %s while waiting for no callback", tc.Name, sig.ImmuneCallBackID)
+		default:
+			log.WithField(immune.LogFieldTestCase, tc.Name).Infof("no callback received for test_case %s within %d seconds", tc.Name, window)
+			return nil
+		}
+	}
+}
+
+// callbackLog returns a log entry with the fields of the callback in sig, received for tc
+func callbackLog(tc *immune.TestCase, sig *immune.Signal) *log.Entry {
+	return log.WithFields(log.Fields{
+		immune.LogFieldTestCase:   tc.Name,
+		immune.LogFieldCallbackID: sig.ImmuneCallBackID,
+		immune.LogFieldAttempt:    sig.Attempt,
+	})
+}
+
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode <= 299
+}
+
+func msToDuration(ms uint) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// deliveryDuration returns how long it took the callback in sig to arrive after sentAt
+func deliveryDuration(sentAt time.Time, sig *immune.Signal) time.Duration {
+	receivedAt := sig.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	return receivedAt.Sub(sentAt)
+}
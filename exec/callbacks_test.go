@@ -0,0 +1,281 @@
+package exec
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_verifyNoDuplicates(t *testing.T) {
+	tests := []struct {
+		name          string
+		history       []immune.Signal
+		wantLogFields log.Fields
+		wantErr       bool
+		wantErrMsg    string
+	}{
+		{
+			name: "should_allow_retries_after_non_2xx",
+			history: []immune.Signal{
+				{ImmuneCallBackID: "abc", Attempt: 1, StatusCode: http.StatusInternalServerError},
+				{ImmuneCallBackID: "abc", Attempt: 2},
+				{ImmuneCallBackID: "abc", Attempt: 3, StatusCode: http.StatusOK},
+			},
+			wantLogFields: log.Fields{
+				immune.LogFieldTestCase:   "create_event",
+				immune.LogFieldCallbackID: "abc",
+				immune.LogFieldAttempt:    3,
+			},
+		},
+		{
+			name: "should_pass_for_single_delivery",
+			history: []immune.Signal{
+				{ImmuneCallBackID: "abc", Attempt: 1, StatusCode: http.StatusOK},
+			},
+		},
+		{
+			name: "should_error_for_delivery_after_2xx",
+			history: []immune.Signal{
+				{ImmuneCallBackID: "abc", Attempt: 1, StatusCode: http.StatusInternalServerError},
+				{ImmuneCallBackID: "abc", Attempt: 2, StatusCode: http.StatusNoContent},
+				{ImmuneCallBackID: "abc", Attempt: 3, StatusCode: http.StatusOK},
+			},
+			wantErr:    true,
+			wantErrMsg: "callback abc: duplicate delivery: attempt 3 arrived after a 2xx response",
+		},
+		{
+			name: "should_allow_fan_out_to_receivers",
+			history: []immune.Signal{
+				{ImmuneCallBackID: "abc", Receiver: "billing", Attempt: 1, StatusCode: http.StatusOK},
+				{ImmuneCallBackID: "abc", Receiver: "orders", Attempt: 1, StatusCode: http.StatusOK},
+			},
+		},
+		{
+			name: "should_error_for_duplicate_at_one_receiver",
+			history: []immune.Signal{
+				{ImmuneCallBackID: "abc", Receiver: "billing", Attempt: 1, StatusCode: http.StatusOK},
+				{ImmuneCallBackID: "abc", Receiver: "orders", Attempt: 1, StatusCode: http.StatusInternalServerError},
+				{ImmuneCallBackID: "abc", Receiver: "orders", Attempt: 2, StatusCode: http.StatusOK},
+				{ImmuneCallBackID: "abc", Receiver: "billing", Attempt: 2, StatusCode: http.StatusOK},
+			},
+			wantErr:    true,
+			wantErrMsg: "callback abc at receiver billing: duplicate delivery: attempt 2 arrived after a 2xx response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := test.NewGlobal()
+			defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+			err := verifyNoDuplicates(&immune.TestCase{Name: "create_event"}, tt.history)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			if tt.wantLogFields == nil {
+				require.Empty(t, hook.AllEntries())
+				return
+			}
+			require.Equal(t, tt.wantLogFields, hook.LastEntry().Data)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_Sequence(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name       string
+		callback   immune.Callback
+		history    map[string][]immune.Signal
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:     "should_pass_for_ordered_callbacks",
+			callback: immune.Callback{Enabled: true, Times: 1, Ordered: true, NoDuplicates: true},
+			history: map[string][]immune.Signal{
+				"1": {{ImmuneCallBackID: "1", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start}},
+				"2": {{ImmuneCallBackID: "2", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start.Add(time.Millisecond)}},
+				"3": {{ImmuneCallBackID: "3", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start.Add(2 * time.Millisecond)}},
+			},
+		},
+		{
+			name:     "should_error_for_out_of_order_callbacks",
+			callback: immune.Callback{Enabled: true, Times: 1, Ordered: true},
+			history: map[string][]immune.Signal{
+				"1": {{ImmuneCallBackID: "1", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start}},
+				"2": {{ImmuneCallBackID: "2", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start.Add(2 * time.Millisecond)}},
+				"3": {{ImmuneCallBackID: "3", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start.Add(time.Millisecond)}},
+			},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: callbacks out of order: event 3 arrived before event 2",
+		},
+		{
+			name:     "should_error_for_duplicate_callback",
+			callback: immune.Callback{Enabled: true, Times: 1, NoDuplicates: true},
+			history: map[string][]immune.Signal{
+				"1": {{ImmuneCallBackID: "1", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start}},
+				"2": {
+					{ImmuneCallBackID: "2", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start},
+					{ImmuneCallBackID: "2", Attempt: 2, StatusCode: http.StatusOK, ReceivedAt: start.Add(time.Second)},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: event 2: callback 2: duplicate delivery: attempt 2 arrived after a 2xx response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			// callback ids are generated in order, 1, 2, 3...
+			ids := []string{"1", "2", "3"}
+			next := 0
+			idFn := func() string {
+				next++
+				return ids[next-1]
+			}
+
+			// callbacks are received in the order they were generated
+			received := 0
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register(gomock.Any(), gomock.Any()).Times(3)
+			// the fourth receive waits out the quiet window
+			mockCallbackServer.EXPECT().ReceiveCallback(gomock.Any(), gomock.Any()).Times(4).Do(func(ctx context.Context, c chan<- *immune.Signal) {
+				if received == len(ids) {
+					<-ctx.Done()
+					return
+				}
+				received++
+				c <- &immune.Signal{ImmuneCallBackID: ids[received-1]}
+			})
+			mockCallbackServer.EXPECT().History(gomock.Any()).AnyTimes().DoAndReturn(func(id string) []immune.Signal {
+				return tt.history[id]
+			})
+			if !tt.wantErr {
+				mockDBTruncator.EXPECT().Truncate(gomock.Any()).Times(1)
+			}
+
+			ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", mockDBTruncator, idFn)
+			ex.quietWindow = 10 * time.Millisecond
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Repeat:       3,
+				Callback:     tt.callback,
+				RequestBody:  immune.M{"data": map[string]interface{}{}},
+			})
+			require.Equal(t, 3, httpmock.GetTotalCallCount())
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_LateDuplicate(t *testing.T) {
+	start := time.Now()
+	delivered := immune.Signal{ImmuneCallBackID: "12345", Attempt: 1, StatusCode: http.StatusOK, ReceivedAt: start}
+	duplicate := immune.Signal{ImmuneCallBackID: "12345", Attempt: 2, StatusCode: http.StatusOK, ReceivedAt: start.Add(5 * time.Millisecond)}
+
+	tests := []struct {
+		name       string
+		callback   immune.Callback
+		signals    []*immune.Signal
+		history    []immune.Signal
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:     "should_pass_without_duplicate_in_quiet_window",
+			callback: immune.Callback{Enabled: true, Min: 1, NoDuplicates: true},
+			signals:  []*immune.Signal{&delivered},
+			history:  []immune.Signal{delivered},
+		},
+		{
+			name:       "should_error_for_duplicate_after_min_callbacks",
+			callback:   immune.Callback{Enabled: true, Min: 1, NoDuplicates: true},
+			signals:    []*immune.Signal{&delivered, &duplicate},
+			history:    []immune.Signal{delivered, duplicate},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: callback 12345: duplicate delivery: attempt 2 arrived after a 2xx response",
+		},
+		{
+			name:       "should_error_for_duplicate_after_exact_callbacks",
+			callback:   immune.Callback{Enabled: true, Times: 1, NoDuplicates: true},
+			signals:    []*immune.Signal{&delivered, &duplicate},
+			history:    []immune.Signal{delivered, duplicate},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: callback 12345: duplicate delivery: attempt 2 arrived after a 2xx response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1)
+			// the duplicate arrives after the callback count is satisfied, the last receive waits out the quiet window
+			mockCallbackServer.EXPECT().ReceiveCallback(gomock.Any(), gomock.Any()).Times(len(tt.signals) + 1).Do(receiveSignals(tt.signals...))
+			mockCallbackServer.EXPECT().History("12345").Return(tt.history)
+			if !tt.wantErr {
+				mockDBTruncator.EXPECT().Truncate(gomock.Any()).Times(1)
+			}
+
+			ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", mockDBTruncator, func() string { return "12345" })
+			ex.quietWindow = 10 * time.Millisecond
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Callback:     tt.callback,
+				RequestBody:  immune.M{"data": map[string]interface{}{}},
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
@@ -3,7 +3,6 @@ package exec
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,7 +12,6 @@ import (
 	"github.com/frain-dev/immune/database"
 	"github.com/frain-dev/immune/url"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 )
 
 // Executor is used to execute tests
@@ -26,6 +24,12 @@ type Executor struct {
 	dbTruncator            database.Truncator
 	vm                     *immune.VariableMap
 	s                      immune.CallbackServer
+	missing                []immune.MissingCallback
+	tracer                 *Tracer
+
+	// quietWindow is how long callbacks are awaited after the expected ones arrived, for
+	// test cases without a quiet window, see waitForCallbacks
+	quietWindow time.Duration
 }
 
 func NewExecutor(
@@ -45,9 +49,15 @@ func NewExecutor(
 		dbTruncator:            dbTruncator,
 		callbackIDLocation:     callbackIDLocation,
 		maxCallbackWaitSeconds: maxCallbackWaitSeconds,
+		quietWindow:            defaultQuietWindow,
 	}
 }
 
+// SetTracer makes the executor trace its requests, responses & callbacks to t
+func (ex *Executor) SetTracer(t *Tracer) {
+	ex.tracer = t
+}
+
 // ExecuteSetupTestCase executes setup test cases
 func (ex *Executor) ExecuteSetupTestCase(ctx context.Context, setupTC *immune.SetupTestCase) error {
 	u, err := url.Parse(fmt.Sprintf("%s%s", ex.baseURL, setupTC.Endpoint))
@@ -61,17 +71,16 @@ func (ex *Executor) ExecuteSetupTestCase(ctx context.Context, setupTC *immune.Se
 	}
 
 	r := &request{
+		label:       "setup_test_case " + setupTC.Name,
 		contentType: "application/json",
 		url:         result,
 		body:        setupTC.RequestBody,
 		method:      setupTC.HTTPMethod,
 	}
 
-	if r.body != nil {
-		err = r.processWithVariableMap(ex.vm)
-		if err != nil {
-			return errors.Wrapf(err, "setup_test_case %s: failed to process request body with variable map", setupTC.Name)
-		}
+	err = r.processWithVariableMap(ex.vm)
+	if err != nil {
+		return errors.Wrapf(err, "setup_test_case %s: failed to process request body with variable map", setupTC.Name)
 	}
 
 	resp, err := ex.sendRequest(ctx, r)
@@ -111,48 +120,168 @@ func (ex *Executor) ExecuteSetupTestCase(ctx context.Context, setupTC *immune.Se
 
 // ExecuteTestCase executes test cases, it waits for callback if necessary
 func (ex *Executor) ExecuteTestCase(ctx context.Context, tc *immune.TestCase) error {
-	u, err := url.Parse(fmt.Sprintf("%s%s", ex.baseURL, tc.Endpoint))
-	if err != nil {
-		return errors.Wrapf(err, "test_case %s: failed to parse url", tc.Name)
+	repeat := tc.Repeat
+	if repeat == 0 {
+		repeat = 1
 	}
 
-	result, err := u.ProcessWithVariableMap(ex.vm)
-	if err != nil {
-		return errors.Wrapf(err, "test_case %s: failed to process parsed url with variable map", tc.Name)
+	events := make([]*sentEvent, 0, repeat)
+	for i := uint(0); i < repeat; i++ {
+		ev, err := ex.sendTestCaseRequest(ctx, tc)
+		if err != nil {
+			return err
+		}
+
+		events = append(events, ev)
 	}
 
-	var uid string
-	if tc.Callback.Enabled {
-		uid = ex.idFn()
-		err = immune.InjectCallbackID(ex.callbackIDLocation, uid, tc.RequestBody)
+	if tc.Callback.Enabled && tc.Callback.ExpectsNone() {
+		err := ex.waitForNoCallback(tc, events)
+		if err != nil {
+			return err
+		}
+	} else if tc.Callback.Enabled {
+		err := ex.waitForCallbacks(tc, events)
 		if err != nil {
-			return errors.Wrapf(err, "test_case %s: failed to inject callback id into request body", tc.Name)
+			return err
 		}
 	}
 
+	return ex.dbTruncator.Truncate(ctx)
+}
+
+// sendTestCaseRequest sends the request of tc with a new callback id and checks the response
+func (ex *Executor) sendTestCaseRequest(ctx context.Context, tc *immune.TestCase) (*sentEvent, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", ex.baseURL, tc.Endpoint))
+	if err != nil {
+		return nil, errors.Wrapf(err, "test_case %s: failed to parse url", tc.Name)
+	}
+
+	result, err := u.ProcessWithVariableMap(ex.vm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "test_case %s: failed to process parsed url with variable map", tc.Name)
+	}
+
 	r := &request{
+		label:       "test_case " + tc.Name,
 		contentType: "application/json",
 		body:        tc.RequestBody,
 		url:         result,
 		method:      tc.HTTPMethod,
+		bodyType:    tc.BodyType,
+		rawBody:     tc.RawBody,
+		files:       tc.Files,
+		header:      http.Header{},
 	}
 
-	if r.body != nil {
-		err = r.processWithVariableMap(ex.vm)
+	var uid string
+	if tc.Callback.Enabled {
+		uid = ex.idFn()
+		err = ex.injectCallbackID(tc, r, uid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "test_case %s", tc.Name)
+		}
+		err = ex.s.Register(uid, &tc.Callback)
 		if err != nil {
-			return errors.Wrapf(err, "test_case %s: failed to process request body with variable map", tc.Name)
+			return nil, errors.Wrapf(err, "test_case %s", tc.Name)
 		}
 	}
 
+	if tc.BodyFile != "" {
+		err = r.loadBodyFile(tc.BodyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "test_case %s", tc.Name)
+		}
+	}
+
+	err = r.processWithVariableMap(ex.vm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "test_case %s: failed to process request body with variable map", tc.Name)
+	}
+
+	sentAt := time.Now()
 	resp, err := ex.sendRequest(ctx, r)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	err = ex.checkTestCaseResponse(tc, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sentEvent{callbackID: uid, sentAt: sentAt}, nil
+}
+
+// injectCallbackID places uid in r at the id location of tc, or at
+// the callback id location of the executor if tc has none
+func (ex *Executor) injectCallbackID(tc *immune.TestCase, r *request, uid string) error {
+	location := tc.Callback.IDLocation
+	if location == "" {
+		location = ex.callbackIDLocation
+	}
+
+	loc, err := immune.ParseCallbackIDLocation(location)
+	if err != nil {
+		return errors.Wrap(err, "failed to inject callback id")
+	}
+
+	switch loc.Source {
+	case immune.CallbackIDSourceHeader:
+		r.header.Set(loc.Name, uid)
+	case immune.CallbackIDSourceQuery:
+		err = r.setQueryParam(loc.Name, uid)
+		if err != nil {
+			return errors.Wrap(err, "failed to inject callback id into query")
+		}
+	default:
+		if hasRawBody(tc) {
+			// raw bodies can't be injected into, they reference the callback id as a variable instead
+			r.vars = map[string]string{immune.CallbackIDFieldName: uid}
+			return nil
+		}
+
+		if tc.Callback.CreateIDLocation {
+			if tc.RequestBody == nil {
+				tc.RequestBody = immune.M{}
+				r.body = tc.RequestBody
+			}
+			err = immune.InjectCallbackIDCreating(loc.Name, uid, tc.RequestBody)
+		} else {
+			err = immune.InjectCallbackID(loc.Name, uid, tc.RequestBody)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "failed to inject callback id into request body")
+		}
+	}
+
+	return nil
+}
+
+// checkTestCaseResponse checks resp against the expectations of tc
+func (ex *Executor) checkTestCaseResponse(tc *immune.TestCase, resp *response) error {
+	// the budget is checked first, a slow error response is reported as slow
+	if tc.MaxDurationMS > 0 && resp.duration > msToDuration(tc.MaxDurationMS) {
+		return errors.Errorf("test_case %s: wants response within %dms but took %dms", tc.Name, tc.MaxDurationMS, resp.duration.Milliseconds())
 	}
 
 	if tc.StatusCode != resp.statusCode {
 		return errors.Errorf("test_case %s: wants status code %d but got status code %d", tc.Name, tc.StatusCode, resp.statusCode)
 	}
 
+	err := assertResponse(tc, resp)
+	if err != nil {
+		return errors.Wrapf(err, "test_case %s", tc.Name)
+	}
+
+	if tc.StoreResponseHeaders != nil {
+		err = ex.vm.ProcessHeaders(tc.StoreResponseHeaders, resp.header)
+		if err != nil {
+			return errors.Wrapf(err, "test_case %s: failed to process response headers", tc.Name)
+		}
+	}
+
 	if tc.ResponseBody {
 		if resp.body.Len() == 0 {
 			return errors.Errorf("test_case %s: wants response body but got no response body: status_code: %d", tc.Name, resp.statusCode)
@@ -170,43 +299,13 @@ func (ex *Executor) ExecuteTestCase(ctx context.Context, tc *immune.TestCase) er
 		}
 	}
 
-	if tc.Callback.Enabled {
-		cctx, cancel := context.WithTimeout(context.Background(), time.Duration(ex.maxCallbackWaitSeconds)*time.Second)
-		defer cancel()
-
-		signalChan := make(chan *immune.Signal, 1)
-
-		for i := uint(1); i <= tc.Callback.Times; i++ {
-			ex.s.ReceiveCallback(signalChan)
-
-			select {
-			case <-cctx.Done():
-				log.Infof("succesfully received %d callbacks for test_case %s before max callback wait seconds elapsed", i-1, tc.Name)
-				break
-			case sig := <-signalChan:
-				if sig.HasError() {
-					return errors.Errorf("test_case %s: callback error: %s", tc.Name, sig.Error())
-				}
-
-				if sig.ImmuneCallBackID != uid {
-					return errors.Errorf("test_case %s: incorrect callback_id: expected_callback_id '%s', got_callback_id '%s'", tc.Name, uid, sig.ImmuneCallBackID)
-				}
-				log.Infof("callback %d for test_case %s received", i, tc.Name)
-			}
-		}
-	}
-
-	return ex.dbTruncator.Truncate(ctx)
+	return nil
 }
 
 func (ex *Executor) sendRequest(ctx context.Context, r *request) (*response, error) {
-	bb := &bytes.Buffer{}
-	if r.body != nil {
-		rb, err := json.Marshal(r.body)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to marshal request body")
-		}
-		bb.Write(rb)
+	bb, contentType, err := r.encode()
+	if err != nil {
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, r.method.String(), r.url, bb)
@@ -214,8 +313,19 @@ func (ex *Executor) sendRequest(ctx context.Context, r *request) (*response, err
 		return nil, err
 	}
 
-	req.Header.Add("Content-Type", r.contentType)
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Add("Content-Type", contentType)
+	ex.tracer.request(r.label, req, bb.Bytes())
+
+	resp, err := ex.doRequest(req)
+	ex.tracer.response(resp, err)
+	return resp, err
+}
 
+func (ex *Executor) doRequest(req *http.Request) (*response, error) {
+	start := time.Now()
 	resp, err := ex.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -226,6 +336,12 @@ func (ex *Executor) sendRequest(ctx context.Context, r *request) (*response, err
 	if err != nil {
 		return nil, err
 	}
+	duration := time.Since(start)
+
+	return &response{body: bytes.NewBuffer(buf), buf: buf, statusCode: resp.StatusCode, header: resp.Header, duration: duration}, nil
+}
 
-	return &response{body: bytes.NewBuffer(buf), buf: buf, statusCode: resp.StatusCode}, nil
+// hasRawBody reports whether tc sends a raw string body rather than its request_body
+func hasRawBody(tc *immune.TestCase) bool {
+	return tc.BodyType == immune.BodyTypeRaw || tc.BodyType == immune.BodyTypeXML || tc.BodyFile != ""
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/frain-dev/immune"
 	"github.com/frain-dev/immune/mocks"
@@ -13,6 +14,18 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// receiveSignals returns a ReceiveCallback mock sending signals in order, after which it waits for the deadline
+func receiveSignals(signals ...*immune.Signal) func(context.Context, chan<- *immune.Signal) {
+	return func(ctx context.Context, c chan<- *immune.Signal) {
+		if len(signals) == 0 {
+			<-ctx.Done()
+			return
+		}
+		c <- signals[0]
+		signals = signals[1:]
+	}
+}
+
 func TestExecutor_ExecuteSetupTestCase(t *testing.T) {
 	ex := NewExecutor(nil, http.DefaultClient, nil, 10, "http://localhost:5005", "data", nil, nil)
 
@@ -353,6 +366,7 @@ func TestExecutor_ExecuteSetupTestCase(t *testing.T) {
 
 func TestExecutor_ExecuteTestCase(t *testing.T) {
 	ex := NewExecutor(nil, http.DefaultClient, nil, 10, "http://localhost:5005", "data", nil, nil)
+	ex.quietWindow = 10 * time.Millisecond
 	type fields struct {
 		vm *immune.VariableMap
 	}
@@ -406,9 +420,9 @@ func TestExecutor_ExecuteTestCase(t *testing.T) {
 			},
 			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) func() {
 				var rc chan<- *immune.Signal
-				server.EXPECT().ReceiveCallback(gomock.AssignableToTypeOf(rc)).Times(2).DoAndReturn(func(c chan<- *immune.Signal) {
-					c <- &immune.Signal{ImmuneCallBackID: "12345"}
-				})
+				// the third receive waits out the quiet window
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(3).Do(
+					receiveSignals(&immune.Signal{ImmuneCallBackID: "12345"}, &immune.Signal{ImmuneCallBackID: "12345"}))
 
 				tr.EXPECT().Truncate(gomock.Any()).Times(1)
 				httpmock.Activate()
@@ -494,7 +508,7 @@ func TestExecutor_ExecuteTestCase(t *testing.T) {
 			},
 			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) func() {
 				var rc chan<- *immune.Signal
-				server.EXPECT().ReceiveCallback(gomock.AssignableToTypeOf(rc)).Times(1).DoAndReturn(func(c chan<- *immune.Signal) {
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(1).DoAndReturn(func(_ context.Context, c chan<- *immune.Signal) {
 					c <- &immune.Signal{Err: errors.New("failed to decode callback body")}
 				})
 				httpmock.Activate()
@@ -823,73 +837,391 @@ func TestExecutor_ExecuteTestCase(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: "test_case abc: does not want a response body but got a response body: '123456'",
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register(gomock.Any(), gomock.Any()).AnyTimes()
+			if tt.arrangeFn != nil {
+				deferFn := tt.arrangeFn(mockCallbackServer, mockDBTruncator)
+				defer deferFn()
+			}
+
+			ex.s = mockCallbackServer
+			ex.dbTruncator = mockDBTruncator
+			ex.vm = tt.fields.vm
+			ex.idFn = tt.idFn
+			err := ex.ExecuteTestCase(tt.args.ctx, tt.args.tc)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_TimeBudgets(t *testing.T) {
+	ex := NewExecutor(nil, http.DefaultClient, nil, 10, "http://localhost:5005", "data", nil, func() string { return "12345" })
+	ex.quietWindow = 10 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		tc           *immune.TestCase
+		arrangeFn    func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator)
+		wantErrRegex string
+	}{
 		{
-			name: "should_error_for_incorrect_callback_id",
-			fields: fields{
-				vm: &immune.VariableMap{
-					VariableToValue: immune.M{
-						"user_id": "1234",
-					},
-				},
+			name: "should_pass_within_budgets",
+			tc: &immune.TestCase{
+				Name:          "abc",
+				StatusCode:    200,
+				HTTPMethod:    "POST",
+				Endpoint:      "/update_user",
+				ResponseBody:  true,
+				MaxDurationMS: 5000,
+				Callback:      immune.Callback{Enabled: true, Times: 1, MaxDeliveryMS: 5000},
+				RequestBody:   immune.M{"data": map[string]interface{}{}},
+			},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(2).Do(
+					receiveSignals(&immune.Signal{ImmuneCallBackID: "12345", ReceivedAt: time.Now()}))
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+
+				httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/update_user",
+					httpmock.NewStringResponder(http.StatusOK, `{"status":true}`))
 			},
-			args: args{
-				ctx: context.Background(),
-				tc: &immune.TestCase{
-					Name:         "abc",
-					Setup:        nil,
-					StatusCode:   200,
-					HTTPMethod:   "POST",
-					Endpoint:     "/update_user",
-					ResponseBody: true,
-					Callback: immune.Callback{
-						Enabled: true,
-						Times:   2,
-					},
-					RequestBody: immune.M{
-						"email": "dan@gmail.com",
-						"phone": 23453530833,
-						"data":  map[string]interface{}{},
-					},
-				},
+		},
+		{
+			name: "should_error_for_slow_response",
+			tc: &immune.TestCase{
+				Name:          "abc",
+				StatusCode:    200,
+				HTTPMethod:    "POST",
+				Endpoint:      "/update_user",
+				ResponseBody:  true,
+				MaxDurationMS: 10,
+				RequestBody:   immune.M{"data": map[string]interface{}{}},
+			},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/update_user",
+					httpmock.NewStringResponder(http.StatusOK, `{"status":true}`).Delay(50*time.Millisecond))
 			},
-			idFn: func() string {
-				return "12345"
+			wantErrRegex: `^test_case abc: wants response within 10ms but took \d+ms$`,
+		},
+		{
+			name: "should_error_for_slow_error_response",
+			tc: &immune.TestCase{
+				Name:          "abc",
+				StatusCode:    200,
+				HTTPMethod:    "POST",
+				Endpoint:      "/update_user",
+				ResponseBody:  true,
+				MaxDurationMS: 10,
+				RequestBody:   immune.M{"data": map[string]interface{}{}},
+			},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/update_user",
+					httpmock.NewStringResponder(http.StatusGatewayTimeout, `{"status":false}`).Delay(50*time.Millisecond))
 			},
-			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) func() {
+			wantErrRegex: `^test_case abc: wants response within 10ms but took \d+ms$`,
+		},
+		{
+			name: "should_error_for_slow_callback",
+			tc: &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   200,
+				HTTPMethod:   "POST",
+				Endpoint:     "/update_user",
+				ResponseBody: true,
+				Callback:     immune.Callback{Enabled: true, Times: 1, MaxDeliveryMS: 100},
+				RequestBody:  immune.M{"data": map[string]interface{}{}},
+			},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
 				var rc chan<- *immune.Signal
-				server.EXPECT().ReceiveCallback(gomock.AssignableToTypeOf(rc)).Times(1).Do(func(rc chan<- *immune.Signal) {
-					rc <- &immune.Signal{ImmuneCallBackID: "1234"}
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(1).Do(func(_ context.Context, c chan<- *immune.Signal) {
+					c <- &immune.Signal{ImmuneCallBackID: "12345", ReceivedAt: time.Now().Add(time.Second)}
 				})
-				httpmock.Activate()
 
 				httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/update_user",
-					httpmock.NewStringResponder(http.StatusOK, `{"immune_callback_id":"12345"}`))
-
-				return func() {
-					httpmock.DeactivateAndReset()
-				}
+					httpmock.NewStringResponder(http.StatusOK, `{"status":true}`))
 			},
-			wantErr:    true,
-			wantErrMsg: "test_case abc: incorrect callback_id: expected_callback_id '12345', got_callback_id '1234'",
+			wantErrRegex: `^test_case abc: wants callback 1 delivered within 100ms but took \d+ms$`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			mockDBTruncator := mocks.NewMockTruncator(ctrl)
 
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
 			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
-			if tt.arrangeFn != nil {
-				deferFn := tt.arrangeFn(mockCallbackServer, mockDBTruncator)
-				defer deferFn()
+			mockCallbackServer.EXPECT().Register(gomock.Any(), gomock.Any()).AnyTimes()
+			tt.arrangeFn(mockCallbackServer, mockDBTruncator)
+
+			ex.s = mockCallbackServer
+			ex.dbTruncator = mockDBTruncator
+			ex.vm = immune.NewVariableMap()
+			err := ex.ExecuteTestCase(context.Background(), tt.tc)
+			if tt.wantErrRegex != "" {
+				require.Error(t, err)
+				require.Regexp(t, tt.wantErrRegex, err.Error())
+				return
 			}
 
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_ExpectNone(t *testing.T) {
+	ex := NewExecutor(nil, http.DefaultClient, nil, 10, "http://localhost:5005", "data", nil, func() string { return "12345" })
+	ex.quietWindow = 10 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		arrangeFn    func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator)
+		wantErrRegex string
+	}{
+		{
+			name: "should_pass_for_no_callback_in_quiet_window",
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(2).DoAndReturn(
+					func() func(context.Context, chan<- *immune.Signal) {
+						calls := 0
+						return func(ctx context.Context, c chan<- *immune.Signal) {
+							calls++
+							if calls == 1 { // callbacks of other test cases are ignored
+								c <- &immune.Signal{ImmuneCallBackID: "abc", ReceivedAt: time.Now()}
+								return
+							}
+							<-ctx.Done()
+						}
+					}())
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+			},
+		},
+		{
+			name: "should_error_for_callback_in_quiet_window",
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(1).Do(func(_ context.Context, c chan<- *immune.Signal) {
+					c <- &immune.Signal{ImmuneCallBackID: "12345"}
+				})
+			},
+			wantErrRegex: `^test_case abc: wants no callback but got callback 12345 after \d+ms$`,
+		},
+		{
+			name: "should_error_for_lost_callback_server",
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(1).Do(func(_ context.Context, c chan<- *immune.Signal) {
+					c <- &immune.Signal{Err: errors.New("lost the link to the remote callback server: EOF")}
+				})
+			},
+			wantErrRegex: `^test_case abc: callback error: lost the link to the remote callback server: EOF$`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1)
+			tt.arrangeFn(mockCallbackServer, mockDBTruncator)
+
 			ex.s = mockCallbackServer
 			ex.dbTruncator = mockDBTruncator
-			ex.vm = tt.fields.vm
-			ex.idFn = tt.idFn
-			err := ex.ExecuteTestCase(tt.args.ctx, tt.args.tc)
+			ex.vm = immune.NewVariableMap()
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Callback:     immune.Callback{Enabled: true, Expect: immune.CallbackExpectNone, QuietWindowSeconds: 1},
+				RequestBody:  immune.M{"data": map[string]interface{}{}},
+			})
+			if tt.wantErrRegex != "" {
+				require.Error(t, err)
+				require.Regexp(t, tt.wantErrRegex, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_RegisterError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+	mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1).
+		Return(errors.New("failed to register callback 12345 with remote callback server: status 401"))
+
+	ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", nil, func() string { return "12345" })
+	err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+		Name:         "abc",
+		StatusCode:   201,
+		HTTPMethod:   "POST",
+		Endpoint:     "/events",
+		ResponseBody: true,
+		Callback:     immune.Callback{Enabled: true, Times: 1},
+		RequestBody:  immune.M{"data": map[string]interface{}{}},
+	})
+	require.Error(t, err)
+	require.Equal(t, "test_case abc: failed to register callback 12345 with remote callback server: status 401", err.Error())
+}
+
+func TestExecutor_ExecuteTestCase_CallbackCount(t *testing.T) {
+	// callbacks are sent by the mock in order, after which it waits for the deadline
+	receiveFn := func(callbackIDs ...string) func(context.Context, chan<- *immune.Signal) {
+		return func(ctx context.Context, c chan<- *immune.Signal) {
+			if len(callbackIDs) == 0 {
+				<-ctx.Done()
+				return
+			}
+			c <- &immune.Signal{ImmuneCallBackID: callbackIDs[0]}
+			callbackIDs = callbackIDs[1:]
+		}
+	}
+
+	tests := []struct {
+		name        string
+		callback    immune.Callback
+		arrangeFn   func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator)
+		wantErr     bool
+		wantErrMsg  string
+		wantMissing []immune.MissingCallback
+	}{
+		{
+			name:     "should_pass_for_min_callbacks",
+			callback: immune.Callback{Enabled: true, Min: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(2).Do(receiveFn("12345", "12345"))
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+			},
+		},
+		{
+			name:     "should_pass_for_max_callbacks",
+			callback: immune.Callback{Enabled: true, Max: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(2).Do(receiveFn("12345"))
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+			},
+		},
+		{
+			name:     "should_error_for_too_many_callbacks",
+			callback: immune.Callback{Enabled: true, Min: 1, Max: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(3).Do(receiveFn("12345", "12345", "12345"))
+			},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: wants at most 2 callbacks but got 3 callbacks",
+		},
+		{
+			name:     "should_pass_for_exact_callbacks",
+			callback: immune.Callback{Enabled: true, Times: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(3).Do(receiveFn("12345", "12345"))
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+			},
+		},
+		{
+			name:     "should_error_for_extra_callback_after_exact_callbacks",
+			callback: immune.Callback{Enabled: true, Times: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(4).Do(receiveFn("12345", "12345", "12345"))
+			},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: wants 2 callbacks but got 3 callbacks",
+		},
+		{
+			name:     "should_ignore_callbacks_of_other_test_cases",
+			callback: immune.Callback{Enabled: true, Times: 1},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(4).Do(receiveFn("stale", "12345", "other"))
+				tr.EXPECT().Truncate(gomock.Any()).Times(1)
+			},
+		},
+		{
+			name:     "should_error_for_missing_callbacks",
+			callback: immune.Callback{Enabled: true, Times: 2},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(2).Do(receiveFn("12345"))
+			},
+			wantErr:     true,
+			wantErrMsg:  "test_case abc: wants 2 callbacks but got 1 callbacks before max callback wait seconds elapsed",
+			wantMissing: []immune.MissingCallback{{TestCase: "abc", CallbackID: "12345", Want: 2, Got: 1}},
+		},
+		{
+			name:     "should_error_for_missing_min_callbacks",
+			callback: immune.Callback{Enabled: true, Min: 1},
+			arrangeFn: func(server *mocks.MockCallbackServer, tr *mocks.MockTruncator) {
+				var rc chan<- *immune.Signal
+				server.EXPECT().ReceiveCallback(gomock.Any(), gomock.AssignableToTypeOf(rc)).Times(1).Do(receiveFn())
+			},
+			wantErr:     true,
+			wantErrMsg:  "test_case abc: wants at least 1 callbacks but got 0 callbacks before max callback wait seconds elapsed",
+			wantMissing: []immune.MissingCallback{{TestCase: "abc", CallbackID: "12345", Want: 1, Got: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1)
+			tt.arrangeFn(mockCallbackServer, mockDBTruncator)
+
+			ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", mockDBTruncator, func() string { return "12345" })
+			ex.quietWindow = 10 * time.Millisecond
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Callback:     tt.callback,
+				RequestBody:  immune.M{"data": map[string]interface{}{}},
+			})
+			require.Equal(t, tt.wantMissing, ex.MissingCallbacks())
 			if tt.wantErr {
 				require.Error(t, err)
 				require.Equal(t, tt.wantErrMsg, err.Error())
@@ -900,3 +1232,84 @@ func TestExecutor_ExecuteTestCase(t *testing.T) {
 		})
 	}
 }
+
+func TestExecutor_injectCallbackID(t *testing.T) {
+	tests := []struct {
+		name       string
+		idLocation string
+		callback   immune.Callback
+		body       immune.M
+		bodyType   immune.BodyType
+		wantURL    string
+		wantHeader string
+		wantBody   immune.M
+		wantVars   map[string]string
+		wantErrMsg string
+	}{
+		{
+			name:       "should_inject_into_body_at_default_location",
+			idLocation: "data",
+			body:       immune.M{"data": map[string]interface{}{}},
+			wantURL:    "http://localhost:5005/events",
+			wantBody:   immune.M{"data": map[string]interface{}{immune.CallbackIDFieldName: "12345"}},
+		},
+		{
+			name:       "should_inject_into_header_of_test_case",
+			idLocation: "data",
+			callback:   immune.Callback{IDLocation: "header:X-Callback-ID"},
+			wantURL:    "http://localhost:5005/events",
+			wantHeader: "12345",
+		},
+		{
+			name:       "should_inject_into_query_of_test_case",
+			idLocation: "data",
+			callback:   immune.Callback{IDLocation: "query:callback_id"},
+			wantURL:    "http://localhost:5005/events?callback_id=12345",
+		},
+		{
+			name:       "should_create_missing_objects",
+			idLocation: "data.ref",
+			callback:   immune.Callback{CreateIDLocation: true},
+			wantURL:    "http://localhost:5005/events",
+			wantBody:   immune.M{"data": map[string]interface{}{"ref": map[string]interface{}{immune.CallbackIDFieldName: "12345"}}},
+		},
+		{
+			name:       "should_set_request_variable_for_raw_body",
+			idLocation: "data",
+			bodyType:   immune.BodyTypeRaw,
+			wantURL:    "http://localhost:5005/events",
+			wantVars:   map[string]string{immune.CallbackIDFieldName: "12345"},
+		},
+		{
+			name:       "should_error_for_missing_objects",
+			idLocation: "data",
+			body:       immune.M{},
+			wantErrMsg: "failed to inject callback id into request body: the field data, does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := immune.NewVariableMap()
+			ex := NewExecutor(nil, http.DefaultClient, vm, 10, "http://localhost:5005", tt.idLocation, nil, nil)
+			tc := &immune.TestCase{Name: "abc", Callback: tt.callback, RequestBody: tt.body, BodyType: tt.bodyType}
+			r := &request{url: "http://localhost:5005/events", body: tc.RequestBody, header: http.Header{}}
+
+			err := ex.injectCallbackID(tc, r, "12345")
+			if tt.wantErrMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantURL, r.url)
+			require.Equal(t, tt.wantHeader, r.header.Get("X-Callback-ID"))
+			require.Equal(t, tt.wantBody, r.body)
+			require.Equal(t, tt.wantVars, r.vars)
+
+			// the callback id of a request never leaks into the variables of later requests
+			_, ok := vm.Get(immune.CallbackIDFieldName)
+			require.False(t, ok)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package exec
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/frain-dev/immune"
@@ -8,15 +11,60 @@ import (
 )
 
 type request struct {
+	// label names the step sending the request in traces
+	label       string
 	contentType string
 	url         string
 	method      immune.Method
 	body        immune.M
+	bodyType    immune.BodyType
+	rawBody     string
+	files       []immune.File
+	header      http.Header
+	// vars are variables of this request only, they take precedence over the variable map
+	vars map[string]string
+}
+
+// loadBodyFile reads the content of path into the raw body of the request
+func (r *request) loadBodyFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read body file")
+	}
+
+	r.rawBody = string(b)
+	return nil
+}
+
+// setQueryParam sets the query parameter key of the request url to value
+func (r *request) setQueryParam(key, value string) error {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse request url")
+	}
+
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	r.url = u.String()
+	return nil
 }
 
 // processWithVariableMap replaces all variable references in the request body with
 // their corresponding values from the variable map
 func (r *request) processWithVariableMap(vm *immune.VariableMap) error {
+	if r.rawBody != "" {
+		raw, err := r.replaceVariables(r.rawBody, vm)
+		if err != nil {
+			return err
+		}
+		r.rawBody = raw
+	}
+
+	if r.body == nil {
+		return nil
+	}
+
 	return r.traverse(r.body, vm)
 }
 
@@ -81,3 +129,14 @@ func getVariableValue(str string, vm *immune.VariableMap) (interface{}, error) {
 
 	return str, nil // return original since, it's not a variable reference
 }
+
+// replaceVariables replaces every variable reference in the format "{variable_name}"
+// found anywhere in str with its value from the request variables or the variable map
+func (r *request) replaceVariables(str string, vm *immune.VariableMap) (string, error) {
+	return immune.ReplaceVariableRefs(str, func(name string) (string, bool) {
+		if value, ok := r.vars[name]; ok {
+			return value, true
+		}
+		return vm.GetString(name)
+	})
+}
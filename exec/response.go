@@ -3,12 +3,16 @@ package exec
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
+	"time"
 )
 
 type response struct {
 	statusCode int
 	buf        []byte
 	body       *bytes.Buffer
+	header     http.Header
+	duration   time.Duration
 }
 
 func (resp *response) Decode(out interface{}) error {
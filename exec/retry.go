@@ -0,0 +1,33 @@
+package exec
+
+import (
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// verifyRetrySchedule checks that the attempts in signals match the expected
+// number of attempts, and that the spacing between them follows the retry strategy
+func verifyRetrySchedule(re *immune.RetryExpectation, signals []*immune.Signal) error {
+	if uint(len(signals)) != re.Attempts {
+		return errors.Errorf("wants %d delivery attempts but got %d delivery attempts", re.Attempts, len(signals))
+	}
+
+	for i := 1; i < len(signals); i++ {
+		got := signals[i].ReceivedAt.Sub(signals[i-1].ReceivedAt)
+		want := re.Interval(i)
+
+		diff := got - want
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff > re.Tolerance() {
+			return errors.Errorf("wants %s between attempt %d and attempt %d (tolerance %s) but got %s",
+				want, i, i+1, re.Tolerance(), got.Round(time.Millisecond))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package exec
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_verifyRetrySchedule(t *testing.T) {
+	start := time.Now()
+	signalsAt := func(offsets ...time.Duration) []*immune.Signal {
+		signals := make([]*immune.Signal, 0, len(offsets))
+		for _, offset := range offsets {
+			signals = append(signals, &immune.Signal{ImmuneCallBackID: "abc", ReceivedAt: start.Add(offset)})
+		}
+		return signals
+	}
+
+	tests := []struct {
+		name       string
+		re         *immune.RetryExpectation
+		signals    []*immune.Signal
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:    "should_verify_constant_schedule",
+			re:      &immune.RetryExpectation{Attempts: 3, Strategy: immune.RetryStrategyConstant, IntervalSeconds: 10, ToleranceMS: 500},
+			signals: signalsAt(0, 10*time.Second, 20200*time.Millisecond),
+		},
+		{
+			name:    "should_verify_exponential_schedule",
+			re:      &immune.RetryExpectation{Attempts: 4, Strategy: immune.RetryStrategyExponential, IntervalSeconds: 2, ToleranceMS: 500},
+			signals: signalsAt(0, 2*time.Second, 6*time.Second, 14*time.Second),
+		},
+		{
+			name:    "should_verify_sub_second_schedule",
+			re:      &immune.RetryExpectation{Attempts: 3, Strategy: immune.RetryStrategyConstant, IntervalMS: 200, ToleranceMS: 50},
+			signals: signalsAt(0, 210*time.Millisecond, 400*time.Millisecond),
+		},
+		{
+			name:       "should_error_for_sub_second_interval_out_of_tolerance",
+			re:         &immune.RetryExpectation{Attempts: 3, Strategy: immune.RetryStrategyExponential, IntervalMS: 250, ToleranceMS: 50},
+			signals:    signalsAt(0, 250*time.Millisecond, 500*time.Millisecond),
+			wantErr:    true,
+			wantErrMsg: "wants 500ms between attempt 2 and attempt 3 (tolerance 50ms) but got 250ms",
+		},
+		{
+			name:       "should_error_for_wrong_attempt_count",
+			re:         &immune.RetryExpectation{Attempts: 3, Strategy: immune.RetryStrategyConstant, IntervalSeconds: 10},
+			signals:    signalsAt(0, 10*time.Second),
+			wantErr:    true,
+			wantErrMsg: "wants 3 delivery attempts but got 2 delivery attempts",
+		},
+		{
+			name:       "should_error_for_interval_out_of_tolerance",
+			re:         &immune.RetryExpectation{Attempts: 3, Strategy: immune.RetryStrategyExponential, IntervalSeconds: 2, ToleranceMS: 500},
+			signals:    signalsAt(0, 2*time.Second, 4*time.Second),
+			wantErr:    true,
+			wantErrMsg: "wants 4s between attempt 2 and attempt 3 (tolerance 500ms) but got 2s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyRetrySchedule(tt.re, tt.signals)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_RetryAttempts(t *testing.T) {
+	start := time.Now()
+	attemptsAt := func(offsets ...time.Duration) []*immune.Signal {
+		signals := make([]*immune.Signal, 0, len(offsets))
+		for i, offset := range offsets {
+			signals = append(signals, &immune.Signal{ImmuneCallBackID: "12345", Attempt: i + 1, ReceivedAt: start.Add(offset)})
+		}
+		return signals
+	}
+
+	tests := []struct {
+		name       string
+		signals    []*immune.Signal
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:    "should_pass_for_expected_attempts",
+			signals: attemptsAt(0, time.Second),
+		},
+		{
+			name:       "should_error_for_extra_attempt",
+			signals:    attemptsAt(0, time.Second, 2*time.Second),
+			wantErr:    true,
+			wantErrMsg: "test_case abc: retry schedule: wants 2 delivery attempts but got 3 delivery attempts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1)
+			// the last receive waits out the quiet window
+			mockCallbackServer.EXPECT().ReceiveCallback(gomock.Any(), gomock.Any()).Times(len(tt.signals) + 1).Do(receiveSignals(tt.signals...))
+			if !tt.wantErr {
+				mockDBTruncator.EXPECT().Truncate(gomock.Any()).Times(1)
+			}
+
+			ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", mockDBTruncator, func() string { return "12345" })
+			ex.quietWindow = 10 * time.Millisecond
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Callback: immune.Callback{
+					Enabled: true,
+					Times:   2,
+					Retry:   &immune.RetryExpectation{Attempts: 2, Strategy: immune.RetryStrategyConstant, IntervalSeconds: 1, ToleranceMS: 100},
+				},
+				RequestBody: immune.M{"data": map[string]interface{}{}},
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExecutor_ExecuteTestCase_RetryAttemptsPerReceiver(t *testing.T) {
+	start := time.Now()
+	attempt := func(receiver string, n int, offset time.Duration) *immune.Signal {
+		return &immune.Signal{ImmuneCallBackID: "12345", Receiver: receiver, Attempt: n, ReceivedAt: start.Add(offset)}
+	}
+
+	tests := []struct {
+		name       string
+		signals    []*immune.Signal
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_pass_for_expected_attempts_at_each_receiver",
+			signals: []*immune.Signal{
+				attempt("billing", 1, 0),
+				attempt("orders", 1, 300*time.Millisecond),
+				attempt("billing", 2, time.Second),
+				attempt("orders", 2, 1300*time.Millisecond),
+			},
+		},
+		{
+			name: "should_error_for_interval_at_one_receiver",
+			signals: []*immune.Signal{
+				attempt("billing", 1, 0),
+				attempt("orders", 1, 300*time.Millisecond),
+				attempt("billing", 2, time.Second),
+				attempt("orders", 2, 2*time.Second),
+			},
+			wantErr:    true,
+			wantErrMsg: "test_case abc: retry schedule: receiver orders: wants 1s between attempt 1 and attempt 2 (tolerance 100ms) but got 1.7s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			httpmock.Activate()
+			defer httpmock.DeactivateAndReset()
+
+			httpmock.RegisterResponder(http.MethodPost, "http://localhost:5005/events",
+				httpmock.NewStringResponder(http.StatusCreated, `{"status":true}`))
+
+			mockDBTruncator := mocks.NewMockTruncator(ctrl)
+			mockCallbackServer := mocks.NewMockCallbackServer(ctrl)
+			mockCallbackServer.EXPECT().Register("12345", gomock.Any()).Times(1)
+			// the last receive waits out the quiet window
+			mockCallbackServer.EXPECT().ReceiveCallback(gomock.Any(), gomock.Any()).Times(len(tt.signals) + 1).Do(receiveSignals(tt.signals...))
+			if !tt.wantErr {
+				mockDBTruncator.EXPECT().Truncate(gomock.Any()).Times(1)
+			}
+
+			ex := NewExecutor(mockCallbackServer, http.DefaultClient, immune.NewVariableMap(), 1, "http://localhost:5005", "data", mockDBTruncator, func() string { return "12345" })
+			ex.quietWindow = 10 * time.Millisecond
+			err := ex.ExecuteTestCase(context.Background(), &immune.TestCase{
+				Name:         "abc",
+				StatusCode:   201,
+				HTTPMethod:   "POST",
+				Endpoint:     "/events",
+				ResponseBody: true,
+				Callback: immune.Callback{
+					Enabled:   true,
+					Times:     4,
+					Receivers: []string{"billing", "orders"},
+					Retry:     &immune.RetryExpectation{Attempts: 2, Strategy: immune.RetryStrategyConstant, IntervalSeconds: 1, ToleranceMS: 100},
+				},
+				RequestBody: immune.M{"data": map[string]interface{}{}},
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
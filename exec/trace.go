@@ -0,0 +1,190 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/redact"
+	"github.com/pkg/errors"
+)
+
+// Tracer writes every request & response of the executor, and every callback it receives,
+// with their secrets redacted. A nil *Tracer traces nothing
+type Tracer struct {
+	mu       sync.Mutex
+	out      io.Writer
+	dir      string
+	redactor *redact.Redactor
+
+	// file is the trace file of the current test case
+	file *os.File
+}
+
+// NewTracer returns a Tracer writing to out, and to a trace file per test case in dir, either
+// can be left empty. redactor masks the secrets of the traces, it can't be nil
+func NewTracer(out io.Writer, dir string, redactor *redact.Redactor) (*Tracer, error) {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create trace directory")
+		}
+	}
+
+	return &Tracer{out: out, dir: dir, redactor: redactor}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// StartTestCase starts the traces of the test case named name, including its setups
+func (t *Tracer) StartTestCase(name string) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dir != "" {
+		path := filepath.Join(t.dir, unsafeFileChars.ReplaceAllString(name, "_")+".trace")
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to create trace file")
+		}
+		t.file = f
+	}
+
+	t.printf("=== test_case %s, started at %s\n\n", name, time.Now().Format(time.RFC3339))
+	return nil
+}
+
+// FinishTestCase ends the traces of the current test case, err is the error it failed with
+func (t *Tracer) FinishTestCase(name string, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.printf("=== test_case %s failed: %s\n\n", name, err)
+	} else {
+		t.printf("=== test_case %s passed\n\n", name)
+	}
+
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+// request traces req, label names the step sending it e.g setup_test_case setup_app
+func (t *Tracer) request(label string, req *http.Request, body []byte) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.printf("--> %s: %s %s\n", label, req.Method, req.URL.String())
+	t.printHeader(req.Header)
+	t.printBody(req.Header.Get("Content-Type"), body)
+}
+
+// response traces the response to the last request, it is nil if the request failed with err
+func (t *Tracer) response(resp *response, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.printf("<-- request failed: %s\n\n", err)
+		return
+	}
+
+	t.printf("<-- %d %s (%s)\n", resp.statusCode, http.StatusText(resp.statusCode), resp.duration.Round(time.Millisecond))
+	t.printHeader(resp.header)
+	t.printBody(resp.header.Get("Content-Type"), resp.buf)
+}
+
+// callback traces a callback received by the callback server
+func (t *Tracer) callback(sig *immune.Signal) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.printf("<== callback %s at receiver %s, attempt %d, responded %d, received at %s\n",
+		sig.ImmuneCallBackID, sig.Receiver, sig.Attempt, sig.StatusCode, sig.ReceivedAt.Format(time.RFC3339Nano))
+	if sig.Method != "" {
+		t.printf("%s %s\n", sig.Method, sig.Path)
+	}
+	if sig.HasError() {
+		t.printf("error: %s\n", sig.Error())
+	}
+	t.printHeader(sig.Header)
+	t.printBody(sig.Header.Get("Content-Type"), sig.Body)
+}
+
+func (t *Tracer) printHeader(h http.Header) {
+	h = t.redactor.Header(h)
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range h[name] {
+			t.printf("%s: %s\n", name, v)
+		}
+	}
+}
+
+// printBody prints json bodies indented, and text bodies as is
+func (t *Tracer) printBody(contentType string, body []byte) {
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		buf := &bytes.Buffer{}
+		_ = json.Indent(buf, body, "", "  ")
+		t.printf("\n%s\n", buf.String())
+	case utf8.Valid(body) && !strings.HasPrefix(contentType, "multipart/"):
+		t.printf("\n%s\n", body)
+	default:
+		t.printf("\n(%d bytes of %s)\n", len(body), contentType)
+	}
+	t.printf("\n")
+}
+
+// printf writes to the output & the trace file of the current test case, with secrets redacted
+func (t *Tracer) printf(format string, args ...interface{}) {
+	s := t.redactor.String(fmt.Sprintf(format, args...))
+
+	if t.out != nil {
+		_, _ = io.WriteString(t.out, s)
+	}
+
+	if t.file != nil {
+		_, _ = io.WriteString(t.file, s)
+	}
+}
@@ -0,0 +1,90 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/redact"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"123","token":"abc"}`))
+	}))
+	defer srv.Close()
+
+	redactor := redact.New(redact.DefaultFields...)
+	redactor.AddValues("s3cr3t")
+
+	out := &bytes.Buffer{}
+	dir := t.TempDir()
+	tracer, err := NewTracer(out, dir, redactor)
+	require.NoError(t, err)
+
+	ex := NewExecutor(nil, http.DefaultClient, immune.NewVariableMap(), 10, srv.URL, "data", nil, nil)
+	ex.SetTracer(tracer)
+
+	err = tracer.StartTestCase("create app/1")
+	require.NoError(t, err)
+
+	r := &request{
+		label:       "test_case create app/1",
+		contentType: "application/json",
+		url:         srv.URL + "/apps",
+		method:      immune.MethodPost,
+		body:        immune.M{"name": "app", "password": "hunter2"},
+		header:      http.Header{"Authorization": []string{"Bearer s3cr3t"}},
+	}
+	_, err = ex.sendRequest(context.Background(), r)
+	require.NoError(t, err)
+
+	tracer.callback(&immune.Signal{
+		ImmuneCallBackID: "cb-1",
+		Receiver:         immune.DefaultReceiverName,
+		Attempt:          1,
+		StatusCode:       http.StatusOK,
+		Method:           http.MethodPost,
+		Path:             "/cb",
+		Header:           http.Header{"Content-Type": []string{"application/json"}},
+		Body:             []byte(`{"immune_callback_id":"cb-1","secret":"xyz"}`),
+	})
+	tracer.FinishTestCase("create app/1", errors.New("wants status code 200"))
+
+	trace := out.String()
+	require.Contains(t, trace, "=== test_case create app/1, started at")
+	require.Contains(t, trace, "--> test_case create app/1: POST "+srv.URL+"/apps")
+	require.Contains(t, trace, "Authorization: "+redact.Mask)
+	require.Contains(t, trace, `"name": "app"`)
+	require.Contains(t, trace, "<-- 201 Created")
+	require.Contains(t, trace, `"id": "123"`)
+	require.Contains(t, trace, "<== callback cb-1 at receiver default, attempt 1, responded 200")
+	require.Contains(t, trace, "POST /cb")
+	require.Contains(t, trace, "=== test_case create app/1 failed: wants status code 200")
+
+	for _, secret := range []string{"s3cr3t", "hunter2", `"abc"`, "xyz"} {
+		require.NotContains(t, trace, secret)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "create_app_1.trace"))
+	require.NoError(t, err)
+	require.Equal(t, trace, string(b))
+}
+
+func TestTracer_Nil(t *testing.T) {
+	var tracer *Tracer
+	require.NoError(t, tracer.StartTestCase("tc"))
+	tracer.request("tc", httptest.NewRequest(http.MethodGet, "/", nil), nil)
+	tracer.response(nil, errors.New("failed"))
+	tracer.callback(&immune.Signal{})
+	tracer.FinishTestCase("tc", nil)
+}
@@ -11,6 +11,15 @@ import (
 )
 
 func SetupGroup(ctx context.Context, ex *exec.Executor) error {
+	tc, err := GroupSetup()
+	if err != nil {
+		return err
+	}
+	return ex.ExecuteSetupTestCase(ctx, tc)
+}
+
+// GroupSetup returns the setup test case that creates a group, it stores group_id
+func GroupSetup() (*immune.SetupTestCase, error) {
 	req := `{
                 "config": {
                     "disableEndpoint": true,
@@ -34,7 +43,7 @@ func SetupGroup(ctx context.Context, ex *exec.Executor) error {
 	mapper := map[string]interface{}{}
 	err := json.Unmarshal([]byte(req), &mapper)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %v", err)
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
 	tc := &immune.SetupTestCase{
@@ -49,10 +58,19 @@ func SetupGroup(ctx context.Context, ex *exec.Executor) error {
 		StatusCode:   201,
 	}
 
-	return ex.ExecuteSetupTestCase(ctx, tc)
+	return tc, nil
 }
 
 func SetupApp(ctx context.Context, ex *exec.Executor) error {
+	tc, err := AppSetup()
+	if err != nil {
+		return err
+	}
+	return ex.ExecuteSetupTestCase(ctx, tc)
+}
+
+// AppSetup returns the setup test case that creates an app in the group, it stores app_id
+func AppSetup() (*immune.SetupTestCase, error) {
 	const req = `{
              "name": "retro-app-%s",
 			 "support_email": "retro_app-%s@gmail.com"
@@ -64,7 +82,7 @@ func SetupApp(ctx context.Context, ex *exec.Executor) error {
 	mapper := map[string]interface{}{}
 	err := json.Unmarshal([]byte(r), &mapper)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %v", err)
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
 	tc := &immune.SetupTestCase{
@@ -79,10 +97,20 @@ func SetupApp(ctx context.Context, ex *exec.Executor) error {
 		StatusCode:   201,
 	}
 
-	return ex.ExecuteSetupTestCase(ctx, tc)
+	return tc, nil
 }
 
 func SetupAppEndpoint(ctx context.Context, targetURL string, ex *exec.Executor) error {
+	tc, err := AppEndpointSetup(targetURL)
+	if err != nil {
+		return err
+	}
+	return ex.ExecuteSetupTestCase(ctx, tc)
+}
+
+// AppEndpointSetup returns the setup test case that creates an endpoint for
+// targetURL on the app, it stores endpoint_id
+func AppEndpointSetup(targetURL string) (*immune.SetupTestCase, error) {
 	req := `{
              "url": "%s",
                 "secret": "12345",
@@ -96,7 +124,7 @@ func SetupAppEndpoint(ctx context.Context, targetURL string, ex *exec.Executor)
 	mapper := map[string]interface{}{}
 	err := json.Unmarshal([]byte(req), &mapper)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %v", err)
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
 	tc := &immune.SetupTestCase{
@@ -111,10 +139,19 @@ func SetupAppEndpoint(ctx context.Context, targetURL string, ex *exec.Executor)
 		StatusCode:   201,
 	}
 
-	return ex.ExecuteSetupTestCase(ctx, tc)
+	return tc, nil
 }
 
 func SetupEvent(ctx context.Context, ex *exec.Executor) error {
+	tc, err := EventSetup()
+	if err != nil {
+		return err
+	}
+	return ex.ExecuteSetupTestCase(ctx, tc)
+}
+
+// EventSetup returns the setup test case that sends an event to the app, it stores event_id
+func EventSetup() (*immune.SetupTestCase, error) {
 	// uses a random event type so convoy will discard the event
 	req := `{
                 "app_id": "{app_id}",
@@ -129,7 +166,7 @@ func SetupEvent(ctx context.Context, ex *exec.Executor) error {
 	mapper := map[string]interface{}{}
 	err := json.Unmarshal([]byte(req), &mapper)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %v", err)
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
 	tc := &immune.SetupTestCase{
@@ -144,5 +181,5 @@ func SetupEvent(ctx context.Context, ex *exec.Executor) error {
 		StatusCode:   201,
 	}
 
-	return ex.ExecuteSetupTestCase(ctx, tc)
+	return tc, nil
 }
@@ -2,3 +2,4 @@ package immune
 
 //go:generate mockgen --source callback.go --destination mocks/callback.go -package mocks
 //go:generate mockgen --source database/truncator.go --destination mocks/truncator.go -package mocks
+//go:generate sh -c "go run ./cmd schema > schema/immune.v1.schema.json"
@@ -0,0 +1,13 @@
+package immune
+
+// HeaderAssertion is an assertion made on a single response header. With only
+// Name set, the header is asserted to be present.
+type HeaderAssertion struct {
+	Name string `json:"name"`
+	// Value is the exact value the header must have
+	Value string `json:"value"`
+	// Regex is a regular expression the header value must match
+	Regex string `json:"regex"`
+	// Absent asserts that the header must not be in the response
+	Absent bool `json:"absent"`
+}
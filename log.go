@@ -0,0 +1,11 @@
+package immune
+
+// The fields of structured log entries, they are consistent across packages so
+// logs can be filtered by run, test case, setup step or callback
+const (
+	LogFieldRunID      = "run_id"
+	LogFieldTestCase   = "test_case"
+	LogFieldSetupStep  = "setup_step"
+	LogFieldCallbackID = "callback_id"
+	LogFieldAttempt    = "attempt"
+)
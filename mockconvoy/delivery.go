@@ -0,0 +1,152 @@
+package mockconvoy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errSimulatedFailure = errors.New("simulated delivery failure")
+
+// deliver sends the data of ev to e until it responds with a 2xx status code, or the retry
+// limit of group g is reached, attempts are spaced by the retry interval of g
+func (s *Server) deliver(g *Group, e *Endpoint, ev *Event) {
+	defer s.wg.Done()
+
+	retryLimit := g.Config.Strategy.Default.RetryLimit
+	if retryLimit == 0 {
+		retryLimit = defaultRetryLimit
+	}
+
+	interval := time.Duration(g.Config.Strategy.Default.IntervalSeconds) * time.Second
+	if interval == 0 {
+		interval = defaultIntervalSeconds * time.Second
+	}
+	if s.cfg.RetryIntervalMS > 0 {
+		interval = time.Duration(s.cfg.RetryIntervalMS) * time.Millisecond
+	}
+
+	for attempt := uint(1); attempt <= retryLimit+1; attempt++ {
+		if attempt > 1 && !s.wait(interval) {
+			return
+		}
+
+		if !s.wait(time.Duration(s.cfg.LatencyMS) * time.Millisecond) {
+			return
+		}
+
+		d := Delivery{EventID: ev.UID, EndpointID: e.UID, Attempt: attempt, SentAt: time.Now()}
+		statusCode, err := s.send(g, e, ev)
+		d.StatusCode = statusCode
+		if err != nil {
+			d.Error = err.Error()
+		}
+
+		s.mu.Lock()
+		s.deliveries = append(s.deliveries, d)
+		s.mu.Unlock()
+
+		if err == nil && statusCode >= 200 && statusCode <= 299 {
+			return
+		}
+
+		log.Infof("mock convoy: attempt %d to deliver event %s to %s failed: status %d, error: %v", attempt, ev.UID, e.TargetURL, statusCode, err)
+	}
+}
+
+// wait waits for d, it returns false if the server is closed first
+func (s *Server) wait(d time.Duration) bool {
+	if d == 0 {
+		return s.ctx.Err() == nil
+	}
+
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// send makes a single signed delivery attempt, failing it at the configured failure rate
+func (s *Server) send(g *Group, e *Endpoint, ev *Event) (int, error) {
+	s.mu.Lock()
+	fail := s.rand.Float64() < s.cfg.FailureRate
+	s.mu.Unlock()
+
+	if fail {
+		return 0, errSimulatedFailure
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, e.TargetURL, bytes.NewReader(ev.Data))
+	if err != nil {
+		return 0, err
+	}
+
+	header, signature, err := s.sign(g, e.Secret, ev.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Convoy/mock")
+	req.Header.Set(header, signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// sign returns the signature header of group g and the hex encoded hmac of payload with secret
+func (s *Server) sign(g *Group, secret string, payload []byte) (string, string, error) {
+	header := g.Config.Signature.Header
+	if header == "" {
+		header = s.cfg.SignatureHeader
+	}
+
+	hashName := g.Config.Signature.Hash
+	if hashName == "" {
+		hashName = s.cfg.SignatureHash
+	}
+
+	fn, err := hashFunc(hashName)
+	if err != nil {
+		return "", "", err
+	}
+
+	return header, Sign(fn, secret, payload), nil
+}
+
+// Sign returns the hex encoded hmac of payload with secret, as convoy signs deliveries
+func Sign(fn func() hash.Hash, secret string, payload []byte) string {
+	mac := hmac.New(fn, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hashFunc(name string) (func() hash.Hash, error) {
+	switch strings.ToUpper(name) {
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, errors.New("unsupported signature hash " + name)
+	}
+}
@@ -0,0 +1,95 @@
+package mockconvoy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// DefaultSignatureHeader & DefaultSignatureHash sign deliveries of groups without a signature config
+	DefaultSignatureHeader = "X-Convoy-Signature"
+	DefaultSignatureHash   = "SHA256"
+
+	// defaultRetryLimit & defaultIntervalSeconds retry deliveries of groups without a strategy config
+	defaultRetryLimit      = 3
+	defaultIntervalSeconds = 10
+)
+
+type Group struct {
+	UID       string      `json:"uid"`
+	Name      string      `json:"name"`
+	LogoURL   string      `json:"logo_url"`
+	Config    GroupConfig `json:"config"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type GroupConfig struct {
+	DisableEndpoint bool            `json:"disableEndpoint"`
+	Signature       SignatureConfig `json:"signature"`
+	Strategy        StrategyConfig  `json:"strategy"`
+}
+
+type SignatureConfig struct {
+	Hash   string `json:"hash"`
+	Header string `json:"header"`
+}
+
+type StrategyConfig struct {
+	Type    string `json:"type"`
+	Default struct {
+		IntervalSeconds uint `json:"intervalSeconds"`
+		RetryLimit      uint `json:"retryLimit"`
+	} `json:"default"`
+}
+
+type Application struct {
+	UID          string      `json:"uid"`
+	GroupID      string      `json:"group_id"`
+	Name         string      `json:"name"`
+	SupportEmail string      `json:"support_email"`
+	Endpoints    []*Endpoint `json:"endpoints"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+type Endpoint struct {
+	UID         string    `json:"uid"`
+	TargetURL   string    `json:"url"`
+	Secret      string    `json:"secret"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Subscribes reports whether the endpoint receives events of eventType,
+// an endpoint without events or with the "*" event receives every event
+func (e *Endpoint) Subscribes(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+
+	for _, ev := range e.Events {
+		if ev == eventType || ev == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	UID       string          `json:"uid"`
+	AppID     string          `json:"app_id"`
+	EventType string          `json:"event_type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Delivery is a single attempt to deliver an event to an endpoint
+type Delivery struct {
+	EventID    string `json:"event_id"`
+	EndpointID string `json:"endpoint_id"`
+	Attempt    uint   `json:"attempt"`
+	// StatusCode is 0 when the endpoint was not reached
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+}
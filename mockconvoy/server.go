@@ -0,0 +1,341 @@
+// Package mockconvoy is a fake Convoy server, it implements the subset of Convoy's API
+// used by the setup functions of immune: groups, applications, endpoints and events.
+// Events are delivered to the endpoints of their application as real webhooks.
+package mockconvoy
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultBasePath is the path Convoy serves its API under
+const DefaultBasePath = "/api/v1"
+
+// Config configures the fake Convoy server
+type Config struct {
+	Port uint `json:"port"`
+	// BasePath defaults to DefaultBasePath
+	BasePath string `json:"base_path"`
+
+	// LatencyMS delays every delivery attempt
+	LatencyMS uint `json:"latency_ms"`
+	// FailureRate is the probability, between 0 and 1, that a delivery
+	// attempt fails without reaching the endpoint
+	FailureRate float64 `json:"failure_rate"`
+	// RetryIntervalMS overrides the retry interval of the group strategy, so
+	// retries can be tested without waiting for convoy's intervals
+	RetryIntervalMS uint `json:"retry_interval_ms"`
+
+	// SignatureHeader & SignatureHash are used for groups whose
+	// config does not set a signature, see DefaultSignatureHeader
+	SignatureHeader string `json:"signature_header"`
+	SignatureHash   string `json:"signature_hash"`
+
+	// Seed seeds the failure rate, 0 uses the current time
+	Seed int64 `json:"seed"`
+}
+
+// Server is a fake Convoy server, it keeps everything in memory
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	groups     map[string]*Group
+	apps       map[string]*Application
+	deliveries []Delivery
+
+	// cancels the deliveries in flight once the server is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a fake Convoy server for cfg, it is served with Handler or ListenAndServe
+func New(cfg Config) *Server {
+	if cfg.BasePath == "" {
+		cfg.BasePath = DefaultBasePath
+	}
+
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = DefaultSignatureHeader
+	}
+
+	if cfg.SignatureHash == "" {
+		cfg.SignatureHash = DefaultSignatureHash
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		rand:   rand.New(rand.NewSource(seed)),
+		groups: map[string]*Group{},
+		apps:   map[string]*Application{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Handler returns the http handler of the convoy API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.cfg.BasePath+"/groups", post(s.createGroup))
+	mux.HandleFunc(s.cfg.BasePath+"/applications", post(s.createApplication))
+	mux.HandleFunc(s.cfg.BasePath+"/applications/", post(s.createEndpoint))
+	mux.HandleFunc(s.cfg.BasePath+"/events", post(s.createEvent))
+	return mux
+}
+
+// ListenAndServe serves the convoy API on the configured port until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", ":"+strconv.FormatUint(uint64(s.cfg.Port), 10))
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+
+	srv := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		cctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(cctx)
+	}()
+
+	log.Infof("mock convoy listening on %s%s", ln.Addr().String(), s.cfg.BasePath)
+	err = srv.Serve(ln)
+	s.Close()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Close stops the deliveries in flight and waits for them to return
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Deliveries returns every delivery attempt made so far
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]Delivery, len(s.deliveries))
+	copy(deliveries, s.deliveries)
+	return deliveries
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	g := &Group{}
+	if !decode(w, r, g) {
+		return
+	}
+
+	if g.Name == "" {
+		respond(w, http.StatusBadRequest, "please provide a valid name", nil)
+		return
+	}
+
+	g.UID = uuid.New().String()
+	g.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	s.groups[g.UID] = g
+	s.mu.Unlock()
+
+	respond(w, http.StatusCreated, "Group created successfully", g)
+}
+
+func (s *Server) createApplication(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.groupOf(w, r)
+	if !ok {
+		return
+	}
+
+	app := &Application{}
+	if !decode(w, r, app) {
+		return
+	}
+
+	if app.Name == "" {
+		respond(w, http.StatusBadRequest, "please provide your appName", nil)
+		return
+	}
+
+	app.UID = uuid.New().String()
+	app.GroupID = g.UID
+	app.Endpoints = []*Endpoint{}
+	app.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	s.apps[app.UID] = app
+	s.mu.Unlock()
+
+	respond(w, http.StatusCreated, "App created successfully", app)
+}
+
+// createEndpoint serves /applications/{app_id}/endpoints
+func (s *Server) createEndpoint(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, s.cfg.BasePath+"/applications/"), "/")
+	if len(parts) != 2 || parts[1] != "endpoints" {
+		respond(w, http.StatusNotFound, "not found", nil)
+		return
+	}
+
+	g, ok := s.groupOf(w, r)
+	if !ok {
+		return
+	}
+
+	app, ok := s.appOf(w, g, parts[0])
+	if !ok {
+		return
+	}
+
+	e := &Endpoint{}
+	if !decode(w, r, e) {
+		return
+	}
+
+	if e.TargetURL == "" {
+		respond(w, http.StatusBadRequest, "please provide your url", nil)
+		return
+	}
+
+	e.UID = uuid.New().String()
+	e.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	app.Endpoints = append(app.Endpoints, e)
+	s.mu.Unlock()
+
+	respond(w, http.StatusCreated, "App endpoint created successfully", e)
+}
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.groupOf(w, r)
+	if !ok {
+		return
+	}
+
+	ev := &Event{}
+	if !decode(w, r, ev) {
+		return
+	}
+
+	if ev.EventType == "" {
+		respond(w, http.StatusBadRequest, "please provide an event type", nil)
+		return
+	}
+
+	if len(ev.Data) == 0 {
+		respond(w, http.StatusBadRequest, "please provide your data", nil)
+		return
+	}
+
+	app, ok := s.appOf(w, g, ev.AppID)
+	if !ok {
+		return
+	}
+
+	ev.UID = uuid.New().String()
+	ev.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	endpoints := make([]*Endpoint, 0, len(app.Endpoints))
+	for _, e := range app.Endpoints {
+		if e.Subscribes(ev.EventType) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.ctx.Err() == nil { // no deliveries once the server is closed
+		for _, e := range endpoints {
+			s.wg.Add(1)
+			go s.deliver(g, e, ev)
+		}
+	}
+
+	respond(w, http.StatusCreated, "App event created successfully", ev)
+}
+
+// groupOf returns the group of the groupId query parameter, it responds with an error if there is none
+func (s *Server) groupOf(w http.ResponseWriter, r *http.Request) (*Group, bool) {
+	s.mu.Lock()
+	g, ok := s.groups[r.URL.Query().Get("groupId")]
+	s.mu.Unlock()
+
+	if !ok {
+		respond(w, http.StatusNotFound, "group not found", nil)
+	}
+	return g, ok
+}
+
+// appOf returns the application id of group g, it responds with an error if there is none
+func (s *Server) appOf(w http.ResponseWriter, g *Group, id string) (*Application, bool) {
+	s.mu.Lock()
+	app, ok := s.apps[id]
+	s.mu.Unlock()
+
+	if !ok || app.GroupID != g.UID {
+		respond(w, http.StatusNotFound, "application not found", nil)
+		return nil, false
+	}
+	return app, true
+}
+
+// response is the envelope of every convoy API response
+type response struct {
+	Status  bool        `json:"status"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func respond(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(&response{Status: statusCode < 300, Message: message, Data: data})
+	if err != nil {
+		log.WithError(err).Error("failed to write mock convoy response")
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		respond(w, http.StatusBadRequest, "Request is invalid: "+err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+func post(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respond(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+			return
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,166 @@
+package mockconvoy
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// receiver records the webhooks delivered to it, responding with statusCodes in order
+type receiver struct {
+	mu          sync.Mutex
+	statusCodes []int
+	bodies      []string
+	signatures  []string
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	rv.bodies = append(rv.bodies, string(b))
+	rv.signatures = append(rv.signatures, r.Header.Get("X-Retro-Signature"))
+
+	statusCode := http.StatusOK
+	if len(rv.statusCodes) > 0 {
+		statusCode = rv.statusCodes[0]
+		rv.statusCodes = rv.statusCodes[1:]
+	}
+	w.WriteHeader(statusCode)
+}
+
+func (rv *receiver) received() int {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return len(rv.bodies)
+}
+
+func create(t *testing.T, url string, body string) map[string]interface{} {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	r := &struct {
+		Status bool                   `json:"status"`
+		Data   map[string]interface{} `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(r))
+	require.True(t, r.Status)
+	return r.Data
+}
+
+func TestServer_DeliversEvents(t *testing.T) {
+	rv := &receiver{statusCodes: []int{http.StatusInternalServerError}}
+	endpoint := httptest.NewServer(rv)
+	defer endpoint.Close()
+
+	s := New(Config{RetryIntervalMS: 10, Seed: 1})
+	defer s.Close()
+	convoy := httptest.NewServer(s.Handler())
+	defer convoy.Close()
+
+	base := convoy.URL + DefaultBasePath
+	group := create(t, base+"/groups", `{"name":"immune-group","config":{"signature":{"hash":"SHA512","header":"X-Retro-Signature"},"strategy":{"default":{"intervalSeconds":30,"retryLimit":2}}}}`)
+	groupID := group["uid"].(string)
+
+	app := create(t, base+"/applications?groupId="+groupID, `{"name":"retro-app"}`)
+	appID := app["uid"].(string)
+
+	create(t, base+"/applications/"+appID+"/endpoints?groupId="+groupID, `{"url":"`+endpoint.URL+`","secret":"12345","events":["payment.failed"]}`)
+
+	// the endpoint does not subscribe to this event
+	create(t, base+"/events?groupId="+groupID, `{"app_id":"`+appID+`","event_type":"payment.success","data":{"immune_callback_id":"abc"}}`)
+
+	event := create(t, base+"/events?groupId="+groupID, `{"app_id":"`+appID+`","event_type":"payment.failed","data":{"immune_callback_id":"def"}}`)
+
+	require.Eventually(t, func() bool { return rv.received() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	rv.mu.Lock()
+	require.Equal(t, []string{`{"immune_callback_id":"def"}`, `{"immune_callback_id":"def"}`}, rv.bodies)
+	require.Equal(t, Sign(sha512.New, "12345", []byte(rv.bodies[0])), rv.signatures[0])
+	rv.mu.Unlock()
+
+	deliveries := s.Deliveries()
+	require.Len(t, deliveries, 2)
+	require.Equal(t, event["uid"], deliveries[1].EventID)
+	require.Equal(t, uint(2), deliveries[1].Attempt)
+	require.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	require.Equal(t, http.StatusOK, deliveries[1].StatusCode)
+}
+
+func TestServer_FailureRate(t *testing.T) {
+	rv := &receiver{}
+	endpoint := httptest.NewServer(rv)
+	defer endpoint.Close()
+
+	s := New(Config{FailureRate: 1, RetryIntervalMS: 1})
+	convoy := httptest.NewServer(s.Handler())
+	defer convoy.Close()
+
+	base := convoy.URL + DefaultBasePath
+	groupID := create(t, base+"/groups", `{"name":"immune-group"}`)["uid"].(string)
+	appID := create(t, base+"/applications?groupId="+groupID, `{"name":"retro-app"}`)["uid"].(string)
+	create(t, base+"/applications/"+appID+"/endpoints?groupId="+groupID, `{"url":"`+endpoint.URL+`","secret":"12345"}`)
+	create(t, base+"/events?groupId="+groupID, `{"app_id":"`+appID+`","event_type":"payment.failed","data":{}}`)
+
+	require.Eventually(t, func() bool { return len(s.Deliveries()) == defaultRetryLimit+1 }, 5*time.Second, 10*time.Millisecond)
+	s.Close()
+
+	require.Equal(t, 0, rv.received())
+	for _, d := range s.Deliveries() {
+		require.Equal(t, errSimulatedFailure.Error(), d.Error)
+	}
+}
+
+func TestServer_Validation(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+	convoy := httptest.NewServer(s.Handler())
+	defer convoy.Close()
+
+	base := convoy.URL + DefaultBasePath
+	tests := []struct {
+		name           string
+		url            string
+		body           string
+		wantStatusCode int
+	}{
+		{
+			name:           "should_error_for_unknown_group",
+			url:            base + "/applications?groupId=abc",
+			body:           `{"name":"retro-app"}`,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "should_error_for_group_without_name",
+			url:            base + "/groups",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "should_error_for_invalid_body",
+			url:            base + "/groups",
+			body:           `{`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(tt.url, "application/json", strings.NewReader(tt.body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatusCode, resp.StatusCode)
+		})
+	}
+}
@@ -35,16 +35,58 @@ func (m *MockCallbackServer) EXPECT() *MockCallbackServerMockRecorder {
 	return m.recorder
 }
 
+// History mocks base method.
+func (m *MockCallbackServer) History(id string) []immune.Signal {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", id)
+	ret0, _ := ret[0].([]immune.Signal)
+	return ret0
+}
+
+// History indicates an expected call of History.
+func (mr *MockCallbackServerMockRecorder) History(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockCallbackServer)(nil).History), id)
+}
+
+// Port mocks base method.
+func (m *MockCallbackServer) Port(receiver string) uint {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Port", receiver)
+	ret0, _ := ret[0].(uint)
+	return ret0
+}
+
+// Port indicates an expected call of Port.
+func (mr *MockCallbackServerMockRecorder) Port(receiver interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Port", reflect.TypeOf((*MockCallbackServer)(nil).Port), receiver)
+}
+
 // ReceiveCallback mocks base method.
-func (m *MockCallbackServer) ReceiveCallback(rc chan<- *immune.Signal) {
+func (m *MockCallbackServer) ReceiveCallback(ctx context.Context, rc chan<- *immune.Signal) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "ReceiveCallback", rc)
+	m.ctrl.Call(m, "ReceiveCallback", ctx, rc)
 }
 
 // ReceiveCallback indicates an expected call of ReceiveCallback.
-func (mr *MockCallbackServerMockRecorder) ReceiveCallback(rc interface{}) *gomock.Call {
+func (mr *MockCallbackServerMockRecorder) ReceiveCallback(ctx, rc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveCallback", reflect.TypeOf((*MockCallbackServer)(nil).ReceiveCallback), ctx, rc)
+}
+
+// Register mocks base method.
+func (m *MockCallbackServer) Register(id string, cb *immune.Callback) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", id, cb)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Register indicates an expected call of Register.
+func (mr *MockCallbackServerMockRecorder) Register(id, cb interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveCallback", reflect.TypeOf((*MockCallbackServer)(nil).ReceiveCallback), rc)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockCallbackServer)(nil).Register), id, cb)
 }
 
 // Start mocks base method.
@@ -0,0 +1,155 @@
+// Package redact masks secrets in what immune writes: logs, errors and reports. Secrets are
+// either values, like the ones read from secret references, or the values of fields whose
+// names are sensitive, like the Authorization header or a password in a json body
+package redact
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Mask replaces every secret
+const Mask = "****"
+
+// DefaultFields are the sensitive field names, a field is sensitive
+// if its name contains one of them, regardless of the case
+var DefaultFields = []string{"secret", "password", "authorization", "token"}
+
+// Redactor masks secret values & the values of sensitive fields, it is safe for concurrent use
+type Redactor struct {
+	mu     sync.RWMutex
+	values []string
+	fields []string
+
+	// the patterns of sensitive fields as json fields, headers & query parameters
+	jsonRegex   *regexp.Regexp
+	headerRegex *regexp.Regexp
+	queryRegex  *regexp.Regexp
+}
+
+// New returns a Redactor for DefaultFields & fields
+func New(fields ...string) *Redactor {
+	r := &Redactor{}
+	r.AddFields(append(append([]string{}, DefaultFields...), fields...)...)
+	return r
+}
+
+// AddValues marks values as secret, empty values are ignored
+func (r *Redactor) AddValues(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range values {
+		if v != "" {
+			r.values = append(r.values, v)
+		}
+	}
+
+	// longer values first, so a secret containing another is masked whole
+	sort.SliceStable(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// AddFields marks the fields whose names contain one of fields as sensitive
+func (r *Redactor) AddFields(fields ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, f := range fields {
+		if f != "" {
+			r.fields = append(r.fields, strings.ToLower(f))
+		}
+	}
+
+	quoted := make([]string, 0, len(r.fields))
+	for _, f := range r.fields {
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	name := `[^"\s:=&?]*(?:` + strings.Join(quoted, "|") + `)[^"\s:=&]*`
+
+	// "password": "abc" or "password": 123
+	r.jsonRegex = regexp.MustCompile(`(?i)("` + name + `"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+	// Authorization: Bearer abc, up to the end of the line
+	r.headerRegex = regexp.MustCompile(`(?im)(^|[\s{\[,])(` + name + `:[ \t]*)([^\r\n]+)`)
+	// ?token=abc&
+	r.queryRegex = regexp.MustCompile(`(?i)([?&]` + name + `=)([^&\s"]*)`)
+}
+
+// IsSensitive reports whether the field named name is sensitive
+func (r *Redactor) IsSensitive(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name = strings.ToLower(name)
+	for _, f := range r.fields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks the secrets in s
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+
+	s = r.jsonRegex.ReplaceAllString(s, `${1}"`+Mask+`"`)
+	s = r.headerRegex.ReplaceAllString(s, "${1}${2}"+Mask)
+	s = r.queryRegex.ReplaceAllString(s, "${1}"+Mask)
+	return s
+}
+
+// Header returns a copy of h, with the values of sensitive headers & secrets masked
+func (r *Redactor) Header(h http.Header) http.Header {
+	masked := make(http.Header, len(h))
+	for name, values := range h {
+		masked[name] = make([]string, len(values))
+		for i, v := range values {
+			if r.IsSensitive(name) {
+				v = Mask
+			}
+			masked[name][i] = r.String(v)
+		}
+	}
+	return masked
+}
+
+// Hook returns a logrus hook masking the secrets in the message & fields of every entry
+func (r *Redactor) Hook() log.Hook {
+	return &hook{r: r}
+}
+
+type hook struct {
+	r *Redactor
+}
+
+func (h *hook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *hook) Fire(entry *log.Entry) error {
+	entry.Message = h.r.String(entry.Message)
+
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = h.r.String(v)
+		case error:
+			entry.Data[key] = h.r.String(v.Error())
+		}
+
+		if h.r.IsSensitive(key) {
+			entry.Data[key] = Mask
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package redact
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_String(t *testing.T) {
+	r := New("dsn")
+	r.AddValues("s3cr3t", "", "s3cr3t-longer")
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "should_mask_secret_values",
+			s:    "failed to connect with s3cr3t and s3cr3t-longer",
+			want: "failed to connect with **** and ****",
+		},
+		{
+			name: "should_mask_sensitive_json_fields",
+			s:    `response body: {"url":"http://x","secret":"12345","user":{"Password": 123, "name":"a"}}`,
+			want: `response body: {"url":"http://x","secret":"****","user":{"Password": "****", "name":"a"}}`,
+		},
+		{
+			name: "should_mask_json_fields_containing_a_sensitive_name",
+			s:    `{"client_secret": "abc\"def", "mongo_dsn": "mongodb://x"}`,
+			want: `{"client_secret": "****", "mongo_dsn": "****"}`,
+		},
+		{
+			name: "should_mask_sensitive_headers",
+			s:    "GET /x\nAuthorization: Bearer abc\nContent-Type: application/json",
+			want: "GET /x\nAuthorization: ****\nContent-Type: application/json",
+		},
+		{
+			name: "should_mask_sensitive_query_params",
+			s:    "POST http://x/events?token=abc&groupId=1",
+			want: "POST http://x/events?token=****&groupId=1",
+		},
+		{
+			name: "should_leave_other_text",
+			s:    "test_case abc passed",
+			want: "test_case abc passed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, r.String(tt.s))
+		})
+	}
+}
+
+func TestRedactor_Header(t *testing.T) {
+	r := New()
+	r.AddValues("abc")
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer xyz")
+	h.Set("X-Id", "abc-1")
+
+	masked := r.Header(h)
+	require.Equal(t, Mask, masked.Get("Authorization"))
+	require.Equal(t, Mask+"-1", masked.Get("X-Id"))
+	require.Equal(t, "Bearer xyz", h.Get("Authorization"))
+}
+
+func TestRedactor_Hook(t *testing.T) {
+	r := New()
+	r.AddValues("hunter2")
+
+	buf := &bytes.Buffer{}
+	logger := log.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(&log.TextFormatter{DisableTimestamp: true})
+	logger.AddHook(r.Hook())
+
+	logger.WithField("password", "abc").WithField("detail", "uses hunter2").Error("login with hunter2 failed")
+	require.Equal(t, "level=error msg=\"login with **** failed\" detail=\"uses ****\" password=\"****\"\n", buf.String())
+}
@@ -0,0 +1,15 @@
+package immune
+
+// MissingCallback records callbacks that did not arrive before the deadline
+type MissingCallback struct {
+	TestCase   string
+	CallbackID string
+	Want       uint
+	Got        uint
+}
+
+// SkippedTestCase records a test case that was not run, and why
+type SkippedTestCase struct {
+	TestCase string
+	Reason   string
+}
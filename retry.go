@@ -0,0 +1,40 @@
+package immune
+
+import "time"
+
+type RetryStrategy string
+
+const (
+	RetryStrategyConstant    RetryStrategy = "constant"
+	RetryStrategyExponential RetryStrategy = "exponential"
+)
+
+// RetryExpectation describes the delivery attempts expected for a single
+// callback id, and the spacing expected between them
+type RetryExpectation struct {
+	// Attempts is the expected number of delivery attempts
+	Attempts uint          `json:"attempts"`
+	Strategy RetryStrategy `json:"strategy"`
+	// IntervalSeconds is the constant interval, or the first interval for exponential backoff
+	IntervalSeconds uint `json:"interval_seconds"`
+	// IntervalMS is the interval in milliseconds, for sub-second intervals. It can't be used with IntervalSeconds
+	IntervalMS uint `json:"interval_ms"`
+	// ToleranceMS is how far each interval may deviate from the expected interval
+	ToleranceMS uint `json:"tolerance_ms"`
+}
+
+// Interval returns the expected interval between attempt n and attempt n+1, attempts start from 1
+func (r *RetryExpectation) Interval(n int) time.Duration {
+	interval := time.Duration(r.IntervalSeconds) * time.Second
+	if r.IntervalMS > 0 {
+		interval = time.Duration(r.IntervalMS) * time.Millisecond
+	}
+	if r.Strategy == RetryStrategyExponential {
+		return interval << uint(n-1)
+	}
+	return interval
+}
+
+func (r *RetryExpectation) Tolerance() time.Duration {
+	return time.Duration(r.ToleranceMS) * time.Millisecond
+}
@@ -0,0 +1,162 @@
+// Package schema generates json schemas from go types, following their json tags
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Draft is the json schema draft of the generated schemas
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a json schema, only the keywords needed to describe go types are supported
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type    string        `json:"type,omitempty"`
+	Enum    []interface{} `json:"enum,omitempty"`
+	Minimum *int          `json:"minimum,omitempty"`
+	OneOf   []*Schema     `json:"oneOf,omitempty"`
+
+	Items *Schema `json:"items,omitempty"`
+
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties is false for structs, and the schema of the values for maps
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Override returns the schema of a type whose json encoding does not follow its go type,
+// like types with a custom UnmarshalJSON. StructSchema is available to describe the
+// struct itself, e.g as one of several forms
+type Override func(g *Generator, t reflect.Type) *Schema
+
+// Enum returns an override for a string type whose only valid values are values
+func Enum(values ...string) Override {
+	enum := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		enum = append(enum, v)
+	}
+
+	return func(*Generator, reflect.Type) *Schema {
+		return &Schema{Type: "string", Enum: enum}
+	}
+}
+
+// Generator generates the schema of go types, structs are described once in the
+// definitions of the root schema and referenced everywhere else
+type Generator struct {
+	Overrides map[reflect.Type]Override
+	// Strings replaces the schema of the string fields which are not overridden, when set.
+	// It can reference Definitions, which are added to the definitions of the root schema
+	Strings     *Schema
+	Definitions map[string]*Schema
+
+	definitions map[string]*Schema
+}
+
+// Generate returns the schema of the type of v, which must be a struct or a pointer to one
+func (g *Generator) Generate(v interface{}) *Schema {
+	g.definitions = map[string]*Schema{}
+	for name, def := range g.Definitions {
+		g.definitions[name] = def
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := g.StructSchema(t)
+	s.Schema = Draft
+	if len(g.definitions) > 0 {
+		s.Definitions = g.definitions
+	}
+	return s
+}
+
+// StructSchema returns the schema of the struct t inline, rejecting the fields t does not have
+func (g *Generator) StructSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		s.Properties[name] = g.schemaOf(f.Type)
+	}
+	return s
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if override, ok := g.Overrides[t]; ok {
+		return override(g, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := 0
+		return &Schema{Type: "integer", Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		if g.Strings != nil {
+			return g.Strings
+		}
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 { // encoded as base64
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.definitions[name]; !ok {
+			g.definitions[name] = nil // the struct may reference itself
+			g.definitions[name] = g.StructSchema(t)
+		}
+		return &Schema{Ref: "#/definitions/" + name}
+	default: // interfaces accept any value
+		return &Schema{}
+	}
+}
+
+// jsonName returns the name of f in json, it is empty for fields that are not encoded
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" { // unexported
+		return ""
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type level string
+
+type step struct {
+	Code int `json:"code"`
+}
+
+type node struct {
+	Name     string                 `json:"name"`
+	Count    uint                   `json:"count,omitempty"`
+	Level    level                  `json:"level"`
+	Children []*node                `json:"children"`
+	Labels   map[string]string      `json:"labels"`
+	Body     map[string]interface{} `json:"body"`
+	Steps    []step                 `json:"steps"`
+	Ignored  string                 `json:"-"`
+	internal string
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	g := &Generator{
+		Overrides: map[reflect.Type]Override{
+			reflect.TypeOf(level("")): Enum("low", "high"),
+			reflect.TypeOf(step{}): func(g *Generator, t reflect.Type) *Schema {
+				return &Schema{OneOf: []*Schema{{Type: "integer"}, g.StructSchema(t)}}
+			},
+		},
+	}
+
+	b, err := json.Marshal(g.Generate(&node{}))
+	require.NoError(t, err)
+
+	want := `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"count": {"type": "integer", "minimum": 0},
+			"level": {"type": "string", "enum": ["low", "high"]},
+			"children": {"type": "array", "items": {"$ref": "#/definitions/node"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"body": {"type": "object", "additionalProperties": {}},
+			"steps": {"type": "array", "items": {"oneOf": [
+				{"type": "integer"},
+				{"type": "object", "properties": {"code": {"type": "integer"}}, "additionalProperties": false}
+			]}}
+		},
+		"additionalProperties": false,
+		"definitions": {
+			"node": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"count": {"type": "integer", "minimum": 0},
+					"level": {"type": "string", "enum": ["low", "high"]},
+					"children": {"type": "array", "items": {"$ref": "#/definitions/node"}},
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+					"body": {"type": "object", "additionalProperties": {}},
+					"steps": {"type": "array", "items": {"oneOf": [
+						{"type": "integer"},
+						{"type": "object", "properties": {"code": {"type": "integer"}}, "additionalProperties": false}
+					]}}
+				},
+				"additionalProperties": false
+			}
+		}
+	}`
+	require.JSONEq(t, want, string(b))
+}
+
+func TestGenerator_Strings(t *testing.T) {
+	type config struct {
+		Name   string `json:"name"`
+		Levels []level
+	}
+
+	g := &Generator{
+		Overrides: map[reflect.Type]Override{reflect.TypeOf(level("")): Enum("low")},
+		Strings:   &Schema{OneOf: []*Schema{{Type: "string"}, {Ref: "#/definitions/ref"}}},
+		Definitions: map[string]*Schema{
+			"ref": {Type: "object", Required: []string{"from"}},
+		},
+	}
+
+	b, err := json.Marshal(g.Generate(config{}))
+	require.NoError(t, err)
+
+	want := `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"properties": {
+			"name": {"oneOf": [{"type": "string"}, {"$ref": "#/definitions/ref"}]},
+			"Levels": {"type": "array", "items": {"type": "string", "enum": ["low"]}}
+		},
+		"additionalProperties": false,
+		"definitions": {
+			"ref": {"type": "object", "required": ["from"]}
+		}
+	}`
+	require.JSONEq(t, want, string(b))
+}
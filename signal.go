@@ -1,11 +1,35 @@
 package immune
 
+import (
+	"net/http"
+	"time"
+)
+
 // A Signal represents a single callback
 type Signal struct {
 	// ImmuneCallBackID collects the callback id from the request body, it's json tag
 	// must always match immune.CallbackIDFieldName
 	ImmuneCallBackID string `json:"immune_callback_id"`
 
+	// ReceivedAt is the time the callback server received the callback
+	ReceivedAt time.Time `json:"-"`
+
+	// Attempt is the number of callbacks received for ImmuneCallBackID at Receiver, including this one
+	Attempt int `json:"-"`
+
+	// StatusCode is the status code the callback server responded with
+	StatusCode int `json:"-"`
+
+	// Receiver is the name of the receiver the callback arrived at
+	Receiver string `json:"-"`
+
+	// Method, Path, Header & Body are the request of the callback, they
+	// are only set by the local callback server, for tracing
+	Method string      `json:"-"`
+	Path   string      `json:"-"`
+	Header http.Header `json:"-"`
+	Body   []byte      `json:"-"`
+
 	Err error
 }
 
@@ -0,0 +1,328 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeJSON decodes the json config b into a generic document, with the line & column of every
+// key & item by path, e.g test_cases[0].status_code: immune.json:12:9. See checkDocument
+func decodeJSON(name string, b []byte) (map[string]interface{}, map[string]string, error) {
+	doc := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keeps integers intact when the document is encoded again
+
+	err := dec.Decode(&doc)
+	if err != nil {
+		if e, ok := err.(*json.SyntaxError); ok && e.Offset > 0 {
+			// the offset is right after the invalid character
+			return nil, nil, errors.Errorf("%s: %v", position(name, b, e.Offset-1), err)
+		}
+		return nil, nil, errors.Wrap(err, name)
+	}
+
+	ix := &jsonIndexer{name: name, b: b, dec: json.NewDecoder(bytes.NewReader(b)), positions: map[string]string{}}
+	err = ix.index("")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, name)
+	}
+
+	return doc, ix.positions, nil
+}
+
+// position returns name:line:column of offset in b, lines & columns start from 1
+func position(name string, b []byte, offset int64) string {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+
+	before := b[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("%s:%d:%d", name, line, column)
+}
+
+// jsonIndexer walks the json tokens of a config, recording the position of every key & item
+type jsonIndexer struct {
+	name      string
+	b         []byte
+	dec       *json.Decoder
+	positions map[string]string
+}
+
+// index consumes the next json value, the value at path
+func (ix *jsonIndexer) index(path string) error {
+	tok, err := ix.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		for ix.dec.More() {
+			tok, err = ix.dec.Token()
+			if err != nil {
+				return err
+			}
+
+			// the decoder's offset is right after the key
+			end := ix.dec.InputOffset()
+			start := bytes.LastIndexByte(ix.b[:end-1], '"')
+
+			keyPath := joinPath(path, tok.(string))
+			ix.positions[keyPath] = position(ix.name, ix.b, int64(start))
+
+			err = ix.index(keyPath)
+			if err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; ix.dec.More(); i++ {
+			// the decoder's offset is right after the previous token
+			start := ix.dec.InputOffset()
+			for start < int64(len(ix.b)) && strings.IndexByte(" \t\r\n,", ix.b[start]) >= 0 {
+				start++
+			}
+
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			ix.positions[itemPath] = position(ix.name, ix.b, start)
+
+			err = ix.index(itemPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the closing delimiter
+	_, err = ix.dec.Token()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// fieldOf returns the type of the field of t named key, and whether t has such a field. Maps &
+// interfaces have every field, their values are nil types since they are not checked any further
+// unless they are maps of structs. Like encoding/json, the names are matched case-insensitively
+func fieldOf(t reflect.Type, key string) (reflect.Type, bool) {
+	if t == nil {
+		return nil, true
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+	default:
+		return nil, true
+	}
+
+	var folded reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+
+		if name == key {
+			return f.Type, true
+		}
+
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = f.Type
+		}
+	}
+
+	return folded, folded != nil
+}
+
+// jsonName returns the name of f in json, it is empty for fields that are not decoded
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" { // unexported
+		return ""
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "the configuration"
+	}
+	return path
+}
+
+// decodeYAML decodes the yaml config b into a generic document, with the
+// line & column of every key & item by path, see decodeJSON
+func decodeYAML(name string, b []byte) (map[string]interface{}, map[string]string, error) {
+	root := &yaml.Node{}
+	err := yaml.Unmarshal(b, root)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, name)
+	}
+
+	positions := map[string]string{}
+	indexYAML(name, root, "", positions)
+
+	doc := map[string]interface{}{}
+	err = root.Decode(&doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, name)
+	}
+
+	return doc, positions, nil
+}
+
+// decodeTOML decodes the toml config b into a generic document, toml keys have no
+// positions so errors point at their path, see decodeJSON
+func decodeTOML(name string, b []byte) (map[string]interface{}, map[string]string, error) {
+	doc := map[string]interface{}{}
+	_, err := toml.Decode(string(b), &doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, name)
+	}
+
+	return doc, map[string]string{}, nil
+}
+
+// checkDocument checks the fields & types of doc against the type of v, rejecting every field
+// unknown to the type. positions maps the paths of doc to their line & column in the config
+// named name, they are used when available
+func checkDocument(name string, doc map[string]interface{}, v interface{}, positions map[string]string) error {
+	locate := func(path string) string {
+		if pos, ok := positions[path]; ok {
+			return pos
+		}
+		return fmt.Sprintf("%s: %s", name, describePath(path))
+	}
+
+	var unknown []string
+	walkValue(reflect.TypeOf(v), doc, "", func(path, key string) {
+		unknown = append(unknown, fmt.Sprintf("%s: unknown field %q in %s", locate(joinPath(path, key)), key, describePath(path)))
+	})
+
+	if len(unknown) > 0 {
+		return errors.New(strings.Join(unknown, "\n"))
+	}
+
+	// the types are checked by decoding the document as json, as the config finally is
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+
+	err = json.Unmarshal(b, v)
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		path := jsonFieldPath(e.Field)
+		return errors.Errorf("%s: field %s: cannot use %s as %s", locate(path), path, e.Value, e.Type)
+	}
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+	return nil
+}
+
+// walkValue walks a generic document alongside the go type it decodes into,
+// calling unknown for every field the type does not have
+func walkValue(t reflect.Type, v interface{}, path string, unknown func(path, key string)) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedBodyKeys(value) {
+			fieldType, known := fieldOf(t, key)
+			if !known {
+				unknown(path, key)
+			}
+			walkValue(fieldType, value[key], joinPath(path, key), unknown)
+		}
+	case []interface{}:
+		for i, item := range value {
+			walkValue(elemOf(t), item, fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	case []map[string]interface{}: // arrays of tables in toml
+		for i, item := range value {
+			walkValue(elemOf(t), item, fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+// elemOf returns the type of the items of t, it is nil if t is not a slice or an array
+func elemOf(t reflect.Type) reflect.Type {
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
+
+// indexYAML records the line & column of every key & item of the yaml node n, by path
+func indexYAML(name string, n *yaml.Node, path string, positions map[string]string) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			indexYAML(name, c, path, positions)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			positions[keyPath] = fmt.Sprintf("%s:%d:%d", name, key.Line, key.Column)
+			indexYAML(name, value, keyPath, positions)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			positions[itemPath] = fmt.Sprintf("%s:%d:%d", name, c.Line, c.Column)
+			indexYAML(name, c, itemPath, positions)
+		}
+	}
+}
+
+// jsonFieldPath converts the field of a json type error e.g test_cases.0.repeat to a path e.g test_cases[0].repeat
+func jsonFieldPath(field string) string {
+	parts := strings.Split(field, ".")
+	var path string
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err == nil && path != "" {
+			path += "[" + p + "]"
+			continue
+		}
+		path = joinPath(path, p)
+	}
+	return path
+}
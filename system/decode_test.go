@@ -0,0 +1,145 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkDocument(t *testing.T) {
+	decoders := map[string]func(name string, b []byte) (map[string]interface{}, map[string]string, error){
+		"immune.json": decodeJSON,
+		"immune.yaml": decodeYAML,
+		"immune.toml": decodeTOML,
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		config     string
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_pass_for_known_fields",
+			file: "immune.json",
+			config: `{
+  "base_url": "http://localhost:5005",
+  "callback": {"port": 5006, "receivers": [{"name": "billing", "route": "/billing"}]},
+  "test_cases": [{"name": "a", "status_code": 201, "request_body": {"anything": {"goes": true}}}]
+}`,
+		},
+		{
+			name: "should_error_for_unknown_json_fields",
+			file: "immune.json",
+			config: `{
+  "base_url": "http://localhost:5005",
+  "test_cases": [
+    {
+      "name": "a",
+      "status_cod": 201
+    }
+  ],
+  "callbak": {}
+}`,
+			wantErr: true,
+			wantErrMsg: "immune.json:9:3: unknown field \"callbak\" in the configuration\n" +
+				"immune.json:6:7: unknown field \"status_cod\" in test_cases[0]",
+		},
+		{
+			name: "should_error_for_unknown_nested_json_field",
+			file: "immune.json",
+			config: `{
+  "callback": {
+    "receivers": [{"name": "billing"}, {"name": "orders", "rout": "/orders"}]
+  }
+}`,
+			wantErr:    true,
+			wantErrMsg: "immune.json:3:59: unknown field \"rout\" in callback.receivers[1]",
+		},
+		{
+			name: "should_error_for_json_type",
+			file: "immune.json",
+			config: `{
+  "test_cases": [
+    {"name": "a"},
+    {"name": "b", "repeat": "twice"}
+  ]
+}`,
+			wantErr:    true,
+			wantErrMsg: "immune.json:4:19: field test_cases[1].repeat: cannot use string as uint",
+		},
+		{
+			name: "should_error_for_json_syntax",
+			file: "immune.json",
+			config: `{
+  "base_url": "http://localhost:5005",,
+}`,
+			wantErr:    true,
+			wantErrMsg: "immune.json:2:39: invalid character ',' looking for beginning of object key string",
+		},
+		{
+			name: "should_error_for_unknown_yaml_field",
+			file: "immune.yaml",
+			config: `base_url: http://localhost:5005
+test_cases:
+  - name: a
+    status_code: 201
+    callback:
+      enabled: true
+      tims: 1
+`,
+			wantErr:    true,
+			wantErrMsg: "immune.yaml:7:7: unknown field \"tims\" in test_cases[0].callback",
+		},
+		{
+			name: "should_error_for_yaml_type",
+			file: "immune.yaml",
+			config: `test_cases:
+  - name: a
+    status_code: created
+`,
+			wantErr:    true,
+			wantErrMsg: "immune.yaml:3:5: field test_cases[0].status_code: cannot use string as int",
+		},
+		{
+			name: "should_error_for_unknown_toml_field_without_position",
+			file: "immune.toml",
+			config: `base_url = "http://localhost:5005"
+
+[[test_cases]]
+name = "a"
+status_cod = 201
+`,
+			wantErr:    true,
+			wantErrMsg: "immune.toml: test_cases[0].status_cod: unknown field \"status_cod\" in test_cases[0]",
+		},
+		{
+			name: "should_match_field_names_case_insensitively",
+			file: "immune.yaml",
+			config: `Base_URL: http://localhost:5005
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, positions, err := decoders[tt.file](tt.file, []byte(tt.config))
+			if err == nil {
+				err = checkDocument(tt.file, doc, &System{}, positions)
+			}
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_jsonFieldPath(t *testing.T) {
+	require.Equal(t, "test_cases[0].callback.receivers[12].port", jsonFieldPath("test_cases.0.callback.receivers.12.port"))
+	require.Equal(t, "base_url", jsonFieldPath("base_url"))
+}
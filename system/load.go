@@ -0,0 +1,397 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	testCasesField      = "test_cases"
+	setupTestCasesField = "setup_test_cases"
+	includeField        = "include"
+	profilesField       = "profiles"
+)
+
+// includedFields are the only fields an included file can set
+var includedFields = map[string]bool{
+	"$schema":           true,
+	includeField:        true,
+	testCasesField:      true,
+	setupTestCasesField: true,
+}
+
+// namedSource is a test case or setup test case, and the file it is defined in
+type namedSource struct {
+	name string
+	file string
+}
+
+// loader reads the config files of a system into a single generic document
+type loader struct {
+	// including are the files on the current chain of includes, to detect cycles
+	including      map[string]bool
+	testCases      []namedSource
+	setupTestCases []namedSource
+	secrets        []string
+	profileErrors  map[string]error
+}
+
+// config is the generic document of the config files of a system
+type config struct {
+	doc map[string]interface{}
+	// secrets are the values of the secret references of every file
+	secrets []string
+	// profileErrors are the errors resolving the secret references of each profile, they
+	// are only reported when the profile is selected, see applyProfile
+	profileErrors map[string]error
+}
+
+// loadConfig reads and merges the config files in paths, later files take precedence:
+//   - objects are merged field by field, so a later file only overrides the fields it sets
+//   - test_cases & setup_test_cases are concatenated, in the order of the files
+//   - every other value, including other arrays like callback.receivers, is replaced
+//
+// The test cases & setup test cases of a file come before those of the files it includes,
+// which are pulled in the order they are listed, and glob matches in lexical order
+func loadConfig(paths []string) (*config, error) {
+	l := &loader{including: map[string]bool{}, profileErrors: map[string]error{}}
+
+	merged := map[string]interface{}{}
+	for _, path := range paths {
+		doc, err := l.load(path, "")
+		if err != nil {
+			return nil, err
+		}
+		mergeDocuments(merged, doc)
+	}
+
+	err := checkDuplicates("test case", l.testCases)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDuplicates("setup test case", l.setupTestCases)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config{doc: merged, secrets: l.secrets, profileErrors: l.profileErrors}, nil
+}
+
+// load reads the config file at path and the files it includes, includer
+// is the config file including it, it is empty for the config files of a system
+func (l *loader) load(path string, includer string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.including[abs] {
+		return nil, errors.Errorf("%s: include cycle, %s is already being included", includer, path)
+	}
+	l.including[abs] = true
+	defer delete(l.including, abs)
+
+	doc, err := l.readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if includer != "" {
+		for field := range doc {
+			if !includedFields[field] {
+				return nil, errors.Errorf("%s: included files can only set test_cases, setup_test_cases and include, found %s", path, field)
+			}
+		}
+	}
+
+	resolveFilePaths(doc, filepath.Dir(path))
+
+	l.testCases = append(l.testCases, sourcesOf(doc[testCasesField], path)...)
+	l.setupTestCases = append(l.setupTestCases, sourcesOf(doc[setupTestCasesField], path)...)
+
+	patterns := items(doc[includeField])
+	delete(doc, includeField)
+
+	for _, pattern := range patterns {
+		files, err := expandInclude(path, pattern.(string))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			sub, err := l.load(file, path)
+			if err != nil {
+				return nil, err
+			}
+
+			mergeDocuments(doc, sub)
+		}
+	}
+
+	return doc, nil
+}
+
+// resolveFilePaths resolves the relative body_file & files paths of the test cases
+// of doc from dir, the directory of their config file, as includes are
+func resolveFilePaths(doc map[string]interface{}, dir string) {
+	resolve := func(m map[string]interface{}, key string) {
+		if p, ok := m[key].(string); ok && p != "" && !filepath.IsAbs(p) {
+			m[key] = filepath.Join(dir, p)
+		}
+	}
+
+	for _, item := range items(doc[testCasesField]) {
+		tc, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		resolve(tc, "body_file")
+		for _, file := range items(tc["files"]) {
+			if f, ok := file.(map[string]interface{}); ok {
+				resolve(f, "path")
+			}
+		}
+	}
+}
+
+// expandInclude returns the files matching an include of the config file at path,
+// relative includes are resolved from the directory of the config file
+func expandInclude(path, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(path), pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: include %s", path, pattern)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.Errorf("%s: include %s matches no file", path, pattern)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// readConfigFile decodes the config file at path into a generic document, checking it against
+// System. The format is chosen by the extension: .yaml, .yml, .toml, and json for any other.
+// The environment variables & secret references of the file are resolved, see envExpander
+// and secretResolver
+func (l *loader) readConfigFile(path string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	var positions map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		doc, positions, err = decodeYAML(path, b)
+	case ".toml":
+		doc, positions, err = decodeTOML(path, b)
+	default:
+		doc, positions, err = decodeJSON(path, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e := &envExpander{name: path, positions: positions}
+	_, err = e.expand(reflect.TypeOf(System{}), doc, "")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &secretResolver{name: path, dir: filepath.Dir(path), positions: positions, profileErrors: l.profileErrors}
+	err = r.resolve(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	l.secrets = append(l.secrets, r.secrets...)
+
+	err = checkDocument(path, doc, &System{}, positions)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// envRefRegex matches ${ENV_VAR} & ${ENV_VAR:-default}, and $${ which escapes ${
+var envRefRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// envExpander replaces the references to environment variables in the string values of a
+// config document. Values are expanded once decoded, so that the value of an environment
+// variable can't change the structure of the config. Referencing an unset variable without
+// a default is an error, rather than an empty value
+type envExpander struct {
+	name      string
+	positions map[string]string
+}
+
+// expand expands v, the value at path of a field of type t, it returns the expanded value
+func (e *envExpander) expand(t reflect.Type, v interface{}, path string) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := v.(type) {
+	case string:
+		return e.expandString(t, value, path)
+	case map[string]interface{}:
+		for _, key := range sortedBodyKeys(value) {
+			fieldType, _ := fieldOf(t, key)
+			expanded, err := e.expand(fieldType, value[key], joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			value[key] = expanded
+		}
+	case []interface{}:
+		for i, item := range value {
+			expanded, err := e.expand(elemOf(t), item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			value[i] = expanded
+		}
+	case []map[string]interface{}: // arrays of tables in toml, expanded in place
+		for i, item := range value {
+			_, err := e.expand(elemOf(t), item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// expandString expands s, the value at path of a field of type t. When s is a single
+// reference to a number or boolean field e.g port: ${PORT}, the value takes the field's type
+func (e *envExpander) expandString(t reflect.Type, s string, path string) (interface{}, error) {
+	var err error
+	expanded := envRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+
+		match := envRefRegex.FindStringSubmatch(ref)
+		value, ok := os.LookupEnv(match[1])
+		if ok {
+			return value
+		}
+
+		if match[2] != "" {
+			return match[3]
+		}
+
+		if err == nil {
+			err = errors.Errorf("%s: environment variable %s is not set", e.locate(path), match[1])
+		}
+		return ref
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loc := envRefRegex.FindStringIndex(s)
+	if t == nil || loc == nil || loc[0] != 0 || loc[1] != len(s) || s == "$${" {
+		return expanded, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(expanded, 64); err == nil {
+			return json.Number(expanded), nil
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(expanded); err == nil {
+			return b, nil
+		}
+	}
+	return expanded, nil
+}
+
+func (e *envExpander) locate(path string) string {
+	if pos, ok := e.positions[path]; ok {
+		return pos
+	}
+	return fmt.Sprintf("%s: %s", e.name, path)
+}
+
+// mergeDocuments merges src into dst, see loadConfig for the precedence rules
+func mergeDocuments(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if key == testCasesField || key == setupTestCasesField {
+			dst[key] = append(items(dst[key]), items(value)...)
+			continue
+		}
+
+		srcObject, ok := value.(map[string]interface{})
+		dstObject, dstOk := dst[key].(map[string]interface{})
+		if ok && dstOk {
+			mergeDocuments(dstObject, srcObject)
+			continue
+		}
+
+		dst[key] = value
+	}
+}
+
+// items returns the items of an array of a generic document, toml arrays of tables have their own type
+func items(v interface{}) []interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		return value
+	case []map[string]interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, item)
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// sourcesOf returns the names of the test cases in v, defined in file
+func sourcesOf(v interface{}, file string) []namedSource {
+	var sources []namedSource
+	for _, item := range items(v) {
+		m, _ := item.(map[string]interface{})
+		name, _ := m["name"].(string)
+		sources = append(sources, namedSource{name: name, file: file})
+	}
+	return sources
+}
+
+// checkDuplicates returns an error for the first name defined twice, empty names are left to Clean
+func checkDuplicates(kind string, sources []namedSource) error {
+	seen := map[string]string{}
+	for _, s := range sources {
+		if s.name == "" {
+			continue
+		}
+
+		if file, ok := seen[s.name]; ok {
+			return errors.Errorf("duplicate %s %s, defined in %s and in %s", kind, s.name, file, s.file)
+		}
+		seen[s.name] = s.file
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package system
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func testdata(path ...string) string {
+	return filepath.Join(append([]string{"testdata"}, path...)...)
+}
+
+func testCaseNames(sys *System) []string {
+	var names []string
+	for _, tc := range sys.TestCases {
+		names = append(names, tc.Name)
+	}
+	return names
+}
+
+func TestNewSystem_Formats(t *testing.T) {
+	want, err := NewSystem(Options{}, testdata("formats", "immune.json"))
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:5005/api/v1", want.BaseURL)
+	require.Equal(t, immune.M{"app_id": "{app_id}", "data": map[string]interface{}{"retries": float64(3)}}, want.TestCases[0].RequestBody)
+
+	for _, file := range []string{"immune.yaml", "immune.toml"} {
+		t.Run("should_decode_"+filepath.Ext(file)[1:]+"_like_json", func(t *testing.T) {
+			sys, err := NewSystem(Options{}, testdata("formats", file))
+			require.NoError(t, err)
+
+			require.Equal(t, want.BaseURL, sys.BaseURL)
+			require.Equal(t, want.Callback, sys.Callback)
+			require.Equal(t, want.TestCases, sys.TestCases)
+		})
+	}
+}
+
+func TestNewSystem_Includes(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          []string
+		wantTestCases  []string
+		wantErr        bool
+		wantErrMsg     string
+		wantBaseURL    string
+		wantPort       uint
+		wantReceivers  []string
+		wantSetupCases []string
+	}{
+		{
+			name:           "should_include_files_in_glob_order",
+			files:          []string{testdata("load", "immune.json")},
+			wantTestCases:  []string{"a", "b", "c", "d"},
+			wantSetupCases: []string{"setup_c"},
+			wantBaseURL:    "http://localhost:5005/api/v1",
+			wantPort:       5006,
+		},
+		{
+			name:           "should_merge_later_files_over_earlier_ones",
+			files:          []string{testdata("load", "immune.json"), testdata("load", "override.yaml")},
+			wantTestCases:  []string{"a", "b", "c", "d", "e"},
+			wantSetupCases: []string{"setup_c"},
+			wantBaseURL:    "http://staging:5005/api/v1",
+			wantPort:       7006,
+			wantReceivers:  []string{"billing"},
+		},
+		{
+			name:       "should_error_for_include_cycle",
+			files:      []string{testdata("cycle", "a.yaml")},
+			wantErr:    true,
+			wantErrMsg: testdata("cycle", "b.yaml") + ": include cycle, " + testdata("cycle", "a.yaml") + " is already being included",
+		},
+		{
+			name:       "should_error_for_fields_other_than_test_cases_in_included_file",
+			files:      []string{testdata("included", "immune.yaml")},
+			wantErr:    true,
+			wantErrMsg: testdata("included", "cases.yaml") + ": included files can only set test_cases, setup_test_cases and include, found base_url",
+		},
+		{
+			name:       "should_error_for_include_matching_no_file",
+			files:      []string{testdata("nomatch", "immune.yaml")},
+			wantErr:    true,
+			wantErrMsg: testdata("nomatch", "immune.yaml") + ": include " + testdata("nomatch", "missing", "*.yaml") + " matches no file",
+		},
+		{
+			name:       "should_error_for_duplicate_test_case",
+			files:      []string{testdata("dup", "immune.yaml")},
+			wantErr:    true,
+			wantErrMsg: "duplicate test case a, defined in " + testdata("dup", "immune.yaml") + " and in " + testdata("dup", "cases.yaml"),
+		},
+		{
+			name:       "should_error_for_duplicate_test_case_across_files",
+			files:      []string{testdata("load", "immune.json"), testdata("load", "immune.json")},
+			wantErr:    true,
+			wantErrMsg: "duplicate test case a, defined in " + testdata("load", "immune.json") + " and in " + testdata("load", "immune.json"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys, err := NewSystem(Options{}, tt.files...)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantTestCases, testCaseNames(sys))
+			require.Equal(t, tt.wantBaseURL, sys.BaseURL)
+			require.Equal(t, tt.wantPort, sys.Callback.Port)
+			// objects are merged field by field
+			require.Equal(t, "/webhook", sys.Callback.Route)
+
+			var receivers, setupCases []string
+			for _, r := range sys.Callback.Receivers {
+				receivers = append(receivers, r.Name)
+			}
+			for _, stc := range sys.SetupTestCases {
+				setupCases = append(setupCases, stc.Name)
+			}
+			require.Equal(t, tt.wantReceivers, receivers)
+			require.Equal(t, tt.wantSetupCases, setupCases)
+		})
+	}
+}
+
+func TestNewSystem_IncludedBodyFile(t *testing.T) {
+	sys, err := NewSystem(Options{}, testdata("load", "immune.json"))
+	require.NoError(t, err)
+
+	// body files are relative to the file defining the test case, not to the including file
+	require.Equal(t, "b", sys.TestCases[1].Name)
+	require.Equal(t, testdata("load", "cases", "bodies", "b.xml"), sys.TestCases[1].BodyFile)
+	require.FileExists(t, sys.TestCases[1].BodyFile)
+}
+
+func TestNewSystem_ExpandEnv(t *testing.T) {
+	// the values would break the syntax of the config files if they were expanded before decoding
+	body := "say \"hi\" # not a comment\n  second: line"
+	t.Setenv("LOAD_TEST_BASE_URL", "http://localhost:5005/api/v1")
+	t.Setenv("LOAD_TEST_PORT", "5006")
+	t.Setenv("LOAD_TEST_SSL", "true")
+	t.Setenv("LOAD_TEST_BODY", body)
+
+	for _, file := range []string{"immune.yaml", "immune.json", "immune.toml"} {
+		t.Run("should_expand_"+filepath.Ext(file)[1:]+"_values", func(t *testing.T) {
+			sys, err := NewSystem(Options{}, testdata("env", file))
+			require.NoError(t, err)
+
+			require.Equal(t, "http://localhost:5005/api/v1", sys.BaseURL)
+			require.Equal(t, uint(5006), sys.Callback.Port)
+			require.Equal(t, "/webhook", sys.Callback.Route)
+			require.True(t, sys.Callback.SSL)
+			require.Equal(t, body, sys.TestCases[0].RawBody)
+			require.Equal(t, "${LOAD_TEST_BODY} is "+body, sys.TestCases[1].RawBody)
+		})
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		wantErrMsg string
+	}{
+		{
+			name:       "should_error_for_unset_variable",
+			file:       testdata("env", "unset.yaml"),
+			wantErrMsg: testdata("env", "unset.yaml") + ":3:3: environment variable LOAD_TEST_UNSET is not set",
+		},
+		{
+			name:       "should_error_for_variable_of_wrong_type",
+			file:       testdata("env", "port.yaml"),
+			wantErrMsg: testdata("env", "port.yaml") + ":2:3: field callback.port: cannot use string as uint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSystem(Options{}, tt.file)
+			require.Error(t, err)
+			require.Equal(t, tt.wantErrMsg, err.Error())
+		})
+	}
+}
+
+func Test_mergeDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]interface{}
+		src  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "should_merge_objects_field_by_field",
+			dst:  map[string]interface{}{"callback": map[string]interface{}{"port": 5005, "route": "/webhook"}},
+			src:  map[string]interface{}{"callback": map[string]interface{}{"port": 6006}},
+			want: map[string]interface{}{"callback": map[string]interface{}{"port": 6006, "route": "/webhook"}},
+		},
+		{
+			name: "should_concatenate_test_cases",
+			dst: map[string]interface{}{
+				"test_cases":       []interface{}{map[string]interface{}{"name": "a"}},
+				"setup_test_cases": []interface{}{map[string]interface{}{"name": "setup_a"}},
+			},
+			src: map[string]interface{}{
+				"test_cases":       []map[string]interface{}{{"name": "b"}},
+				"setup_test_cases": []interface{}{map[string]interface{}{"name": "setup_b"}},
+			},
+			want: map[string]interface{}{
+				"test_cases":       []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+				"setup_test_cases": []interface{}{map[string]interface{}{"name": "setup_a"}, map[string]interface{}{"name": "setup_b"}},
+			},
+		},
+		{
+			name: "should_replace_other_values",
+			dst: map[string]interface{}{
+				"base_url": "http://localhost",
+				"callback": map[string]interface{}{"receivers": []interface{}{map[string]interface{}{"name": "a"}}},
+				"database": map[string]interface{}{"type": "mongo"},
+			},
+			src: map[string]interface{}{
+				"base_url": "http://staging",
+				"callback": map[string]interface{}{"receivers": []interface{}{map[string]interface{}{"name": "b"}}},
+				"database": "none",
+			},
+			want: map[string]interface{}{
+				"base_url": "http://staging",
+				"callback": map[string]interface{}{"receivers": []interface{}{map[string]interface{}{"name": "b"}}},
+				"database": "none",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeDocuments(tt.dst, tt.src)
+			require.Equal(t, tt.want, tt.dst)
+		})
+	}
+}
+
+func Test_checkDuplicates(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    []namedSource
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:    "should_pass_for_distinct_names",
+			sources: []namedSource{{name: "a", file: "immune.json"}, {name: "b", file: "cases.yaml"}},
+		},
+		{
+			name:    "should_ignore_empty_names",
+			sources: []namedSource{{file: "immune.json"}, {file: "cases.yaml"}},
+		},
+		{
+			name:       "should_error_for_first_duplicate",
+			sources:    []namedSource{{name: "a", file: "immune.json"}, {name: "b", file: "immune.json"}, {name: "b", file: "cases.yaml"}, {name: "a", file: "cases.yaml"}},
+			wantErr:    true,
+			wantErrMsg: "duplicate test case b, defined in immune.json and in cases.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDuplicates("test case", tt.sources)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
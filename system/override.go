@@ -0,0 +1,211 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Options are applied on top of the config files of a system, see NewSystem for their precedence
+type Options struct {
+	// Profile names the profile of the config overlaid on the config files
+	Profile string
+	// Set are overrides of any field, in the form path=value e.g callback.port=8080
+	// or test_cases[0].status_code=201, values are json, or strings for string fields
+	Set []string
+}
+
+// applyProfile overlays the profile named name on the document of cfg, with
+// the same rules as a config file overrides another, see loadConfig
+func applyProfile(cfg *config, name string) error {
+	doc := cfg.doc
+	profiles, _ := doc[profilesField].(map[string]interface{})
+	delete(doc, profilesField)
+
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := profiles[name].(map[string]interface{})
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return errors.Errorf("unknown profile %s, the config has the profiles: %s", name, list(names))
+	}
+
+	// the test cases are left to the config files, where duplicates are detected
+	for _, field := range []string{profilesField, includeField, testCasesField, setupTestCasesField} {
+		if _, ok := profile[field]; ok {
+			return errors.Errorf("profile %s: profiles cannot set %s", name, field)
+		}
+	}
+
+	if err := cfg.profileErrors[name]; err != nil {
+		return errors.Wrapf(err, "profile %s", name)
+	}
+
+	mergeDocuments(doc, profile)
+	return nil
+}
+
+// applySet applies an override of the form path=value to sys
+func applySet(sys *System, set string) error {
+	parts := strings.SplitN(set, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.Errorf("--set %s: must be of the form path=value", set)
+	}
+
+	path, value := parts[0], parts[1]
+	segments, err := parsePath(path)
+	if err != nil {
+		return errors.Wrapf(err, "--set %s", path)
+	}
+
+	err = setField(reflect.ValueOf(sys).Elem(), segments, value, "")
+	if err != nil {
+		return errors.Wrapf(err, "--set %s", path)
+	}
+	return nil
+}
+
+// parsePath splits a path like test_cases[0].callback.times into its keys & indexes,
+// indexes can also be written as keys e.g test_cases.0.callback.times
+func parsePath(path string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []string
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+			for _, index := range strings.Split(strings.TrimSuffix(part[i+1:], "]"), "][") {
+				indexes = append(indexes, index)
+			}
+			if !strings.HasSuffix(part, "]") {
+				return nil, errors.Errorf("invalid index in %s", part)
+			}
+		}
+
+		if key == "" && len(indexes) == 0 {
+			return nil, errors.New("empty field name")
+		}
+
+		if key != "" {
+			segments = append(segments, key)
+		}
+		segments = append(segments, indexes...)
+	}
+	return segments, nil
+}
+
+// setField sets the field at the path segments of v to value, path is
+// the path of v, allocating the pointers & maps on the way
+func setField(v reflect.Value, segments []string, value string, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), segments, value, path)
+	}
+
+	if len(segments) == 0 {
+		return decodeValue(v, value)
+	}
+
+	segment := segments[0]
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := structField(v, segment)
+		if !ok {
+			return errors.Errorf("unknown field %q in %s", segment, describePath(path))
+		}
+		return setField(field, segments[1:], value, joinPath(path, segment))
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(segment)
+		if err != nil || i < 0 || i >= v.Len() {
+			return errors.Errorf("%s has no item %s, it has %d items", describePath(path), segment, v.Len())
+		}
+		return setField(v.Index(i), segments[1:], value, fmt.Sprintf("%s[%d]", path, i))
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		// map values are not addressable, the item is set on a copy
+		key := reflect.ValueOf(segment).Convert(v.Type().Key())
+		item := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			item.Set(existing)
+		}
+
+		err := setField(item, segments[1:], value, joinPath(path, segment))
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, item)
+		return nil
+	case reflect.Interface:
+		// the values within generic maps like request bodies are generic maps & arrays,
+		// a missing value becomes a map. Other values have no fields to set
+		var generic reflect.Value
+		switch item := v.Interface().(type) {
+		case nil:
+			generic = reflect.ValueOf(map[string]interface{}{})
+		case map[string]interface{}, []interface{}:
+			generic = reflect.ValueOf(item)
+		default:
+			return errors.Errorf("%s has no fields", describePath(path))
+		}
+
+		err := setField(generic, segments, value, path)
+		if err != nil {
+			return err
+		}
+		v.Set(generic)
+		return nil
+	}
+
+	return errors.Errorf("%s has no fields", describePath(path))
+}
+
+// structField returns the field of the struct v named name in json
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if n := jsonName(t.Field(i)); n != "" && n == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// decodeValue decodes value into v, as json, string fields take value as is. Generic
+// values are json if value is valid json, and strings otherwise
+func decodeValue(v reflect.Value, value string) error {
+	raw := []byte(value)
+
+	switch v.Kind() {
+	case reflect.String:
+		raw, _ = json.Marshal(value)
+	case reflect.Interface:
+		if !json.Valid(raw) {
+			raw, _ = json.Marshal(value)
+		}
+	}
+
+	ptr := reflect.New(v.Type())
+	err := json.Unmarshal(raw, ptr.Interface())
+	if err != nil {
+		return errors.Wrapf(err, "invalid value %s", value)
+	}
+
+	v.Set(ptr.Elem())
+	return nil
+}
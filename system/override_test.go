@@ -0,0 +1,363 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePath(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		wantSegments []string
+		wantErr      bool
+		wantErrMsg   string
+	}{
+		{
+			name:         "should_split_keys",
+			path:         "callback.port",
+			wantSegments: []string{"callback", "port"},
+		},
+		{
+			name:         "should_split_indexes",
+			path:         "test_cases[0].callback.receivers[1]",
+			wantSegments: []string{"test_cases", "0", "callback", "receivers", "1"},
+		},
+		{
+			name:         "should_split_indexes_written_as_keys",
+			path:         "test_cases.0.status_code",
+			wantSegments: []string{"test_cases", "0", "status_code"},
+		},
+		{
+			name:         "should_split_consecutive_indexes",
+			path:         "request_body.matrix[1][2]",
+			wantSegments: []string{"request_body", "matrix", "1", "2"},
+		},
+		{
+			name:       "should_error_for_unclosed_index",
+			path:       "test_cases[0.status_code",
+			wantErr:    true,
+			wantErrMsg: "invalid index in test_cases[0",
+		},
+		{
+			name:       "should_error_for_empty_field_name",
+			path:       "callback..port",
+			wantErr:    true,
+			wantErrMsg: "empty field name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := parsePath(tt.path)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSegments, segments)
+		})
+	}
+}
+
+func Test_applySet(t *testing.T) {
+	tests := []struct {
+		name       string
+		set        string
+		want       func(sys *System)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_set_number_field",
+			set:  "callback.port=8080",
+			want: func(sys *System) { sys.Callback.Port = 8080 },
+		},
+		{
+			name: "should_set_string_field_as_is",
+			set:  "base_url=http://staging:5005/api/v1?a=b",
+			want: func(sys *System) { sys.BaseURL = "http://staging:5005/api/v1?a=b" },
+		},
+		{
+			name: "should_set_test_case_field",
+			set:  "test_cases[0].status_code=202",
+			want: func(sys *System) { sys.TestCases[0].StatusCode = 202 },
+		},
+		{
+			name: "should_allocate_pointers",
+			set:  "test_cases.0.callback.response.status_code=500",
+			want: func(sys *System) {
+				sys.TestCases[0].Callback.Response = &immune.CallbackResponse{StatusCode: 500}
+			},
+		},
+		{
+			name: "should_set_json_value",
+			set:  `callback.receivers=[{"name":"billing","route":"/billing"}]`,
+			want: func(sys *System) {
+				sys.Callback.Receivers = []immune.CallbackReceiver{{Name: "billing", Route: "/billing"}}
+			},
+		},
+		{
+			name: "should_set_map_key",
+			set:  "test_cases[0].store_response_headers.location=Location",
+			want: func(sys *System) { sys.TestCases[0].StoreResponseHeaders = immune.S{"location": "Location"} },
+		},
+		{
+			name: "should_set_profile_field",
+			set:  "profiles.staging.callback.port=7006",
+			want: func(sys *System) {
+				sys.Profiles = map[string]*System{"staging": {Callback: immune.CallbackConfiguration{Port: 7006}}}
+			},
+		},
+		{
+			name: "should_set_request_body_value",
+			set:  "test_cases[0].request_body.data.name=renamed",
+			want: func(sys *System) {
+				sys.TestCases[0].RequestBody["data"].(map[string]interface{})["name"] = "renamed"
+			},
+		},
+		{
+			name: "should_create_request_body_objects",
+			set:  "test_cases[0].request_body.metadata.retries=3",
+			want: func(sys *System) {
+				sys.TestCases[0].RequestBody["metadata"] = map[string]interface{}{"retries": float64(3)}
+			},
+		},
+		{
+			name: "should_set_request_body_array_item",
+			set:  "test_cases[0].request_body.data.tags[1]=c",
+			want: func(sys *System) {
+				sys.TestCases[0].RequestBody["data"].(map[string]interface{})["tags"] = []interface{}{"a", "c"}
+			},
+		},
+		{
+			name:       "should_error_for_missing_value",
+			set:        "callback.port",
+			wantErr:    true,
+			wantErrMsg: "--set callback.port: must be of the form path=value",
+		},
+		{
+			name:       "should_error_for_unknown_field",
+			set:        "callback.prt=8080",
+			wantErr:    true,
+			wantErrMsg: `--set callback.prt: unknown field "prt" in callback`,
+		},
+		{
+			name:       "should_error_for_index_out_of_range",
+			set:        "test_cases[1].status_code=201",
+			wantErr:    true,
+			wantErrMsg: "--set test_cases[1].status_code: test_cases has no item 1, it has 1 items",
+		},
+		{
+			name:       "should_error_for_invalid_index",
+			set:        "test_cases[first].status_code=201",
+			wantErr:    true,
+			wantErrMsg: "--set test_cases[first].status_code: test_cases has no item first, it has 1 items",
+		},
+		{
+			name:       "should_error_for_invalid_value",
+			set:        "callback.port=high",
+			wantErr:    true,
+			wantErrMsg: "--set callback.port: invalid value high: invalid character 'h' looking for beginning of value",
+		},
+		{
+			name:       "should_error_for_field_of_string",
+			set:        "base_url.host=localhost",
+			wantErr:    true,
+			wantErrMsg: "--set base_url.host: base_url has no fields",
+		},
+		{
+			name:       "should_error_for_field_of_request_body_string",
+			set:        "test_cases[0].request_body.data.name.first=x",
+			wantErr:    true,
+			wantErrMsg: "--set test_cases[0].request_body.data.name.first: test_cases[0].request_body.data.name has no fields",
+		},
+		{
+			name:       "should_error_for_field_of_request_body_array",
+			set:        "test_cases[0].request_body.data.tags.first=x",
+			wantErr:    true,
+			wantErrMsg: "--set test_cases[0].request_body.data.tags.first: test_cases[0].request_body.data.tags has no item first, it has 2 items",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSystem := func() *System {
+				return &System{
+					BaseURL:  "http://localhost:5005/api/v1",
+					Callback: immune.CallbackConfiguration{Port: 5006},
+					TestCases: []immune.TestCase{
+						{
+							Name:       "a",
+							StatusCode: 201,
+							RequestBody: immune.M{
+								"data": map[string]interface{}{"name": "app", "tags": []interface{}{"a", "b"}},
+							},
+						},
+					},
+				}
+			}
+
+			sys := newSystem()
+			err := applySet(sys, tt.set)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			want := newSystem()
+			tt.want(want)
+			require.Equal(t, want, sys)
+		})
+	}
+}
+
+func Test_applyProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    string
+		want       map[string]interface{}
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:    "should_drop_profiles_without_profile",
+			profile: "",
+			want: map[string]interface{}{
+				"base_url": "http://localhost",
+				"callback": map[string]interface{}{"port": 5006, "route": "/webhook"},
+			},
+		},
+		{
+			name:    "should_overlay_profile",
+			profile: "staging",
+			want: map[string]interface{}{
+				"base_url": "http://staging",
+				"callback": map[string]interface{}{"port": 7006, "route": "/webhook"},
+			},
+		},
+		{
+			name:       "should_error_for_unknown_profile",
+			profile:    "prod",
+			wantErr:    true,
+			wantErrMsg: "unknown profile prod, the config has the profiles: invalid, secure, staging",
+		},
+		{
+			name:       "should_error_for_missing_secret_of_selected_profile",
+			profile:    "secure",
+			wantErr:    true,
+			wantErrMsg: "profile secure: immune.yaml:12:7: secret environment variable MONGO_DSN is not set",
+		},
+		{
+			name:       "should_error_for_profile_setting_test_cases",
+			profile:    "invalid",
+			wantErr:    true,
+			wantErrMsg: "profile invalid: profiles cannot set test_cases",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{
+				doc: map[string]interface{}{
+					"base_url": "http://localhost",
+					"callback": map[string]interface{}{"port": 5006, "route": "/webhook"},
+					"profiles": map[string]interface{}{
+						"staging": map[string]interface{}{
+							"base_url": "http://staging",
+							"callback": map[string]interface{}{"port": 7006},
+						},
+						"secure":  map[string]interface{}{"database": map[string]interface{}{"dsn": ""}},
+						"invalid": map[string]interface{}{"test_cases": []interface{}{}},
+					},
+				},
+				// the secrets of the profiles are resolved when the config is read, see secretResolver
+				profileErrors: map[string]error{
+					"secure": errors.New("immune.yaml:12:7: secret environment variable MONGO_DSN is not set"),
+				},
+			}
+
+			err := applyProfile(cfg, tt.profile)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, cfg.doc)
+		})
+	}
+}
+
+func TestNewSystem_Precedence(t *testing.T) {
+	tests := []struct {
+		name               string
+		opts               Options
+		env                map[string]string
+		wantBaseURL        string
+		wantEventTargetURL string
+		wantPort           uint
+		wantErr            bool
+		wantErrMsg         string
+	}{
+		{
+			name:               "should_use_config_file",
+			wantBaseURL:        "http://localhost:5005/api/v1",
+			wantEventTargetURL: "http://localhost:9000/file",
+			wantPort:           5006,
+		},
+		{
+			name:               "should_overlay_profile_on_config_file",
+			opts:               Options{Profile: "staging"},
+			wantBaseURL:        "http://staging:5005/api/v1",
+			wantEventTargetURL: "http://staging:9000/profile",
+			wantPort:           7006,
+		},
+		{
+			name:               "should_override_profile_with_environment",
+			opts:               Options{Profile: "staging"},
+			env:                map[string]string{"IMMUNE_EVENT_TARGET_URL": "http://staging:9000/env"},
+			wantBaseURL:        "http://staging:5005/api/v1",
+			wantEventTargetURL: "http://staging:9000/env",
+			wantPort:           7006,
+		},
+		{
+			name:               "should_override_environment_with_set",
+			opts:               Options{Profile: "staging", Set: []string{"event_target_url=http://staging:9000/set", "callback.port=8006"}},
+			env:                map[string]string{"IMMUNE_EVENT_TARGET_URL": "http://staging:9000/env"},
+			wantBaseURL:        "http://staging:5005/api/v1",
+			wantEventTargetURL: "http://staging:9000/set",
+			wantPort:           8006,
+		},
+		{
+			name:       "should_error_for_missing_secret_of_selected_profile",
+			opts:       Options{Profile: "secure"},
+			wantErr:    true,
+			wantErrMsg: "profile secure: " + testdata("profiles", "immune.yaml") + ":15:7: secret environment variable PROFILE_TEST_MISSING_DSN is not set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			sys, err := NewSystem(tt.opts, testdata("profiles", "immune.yaml"))
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBaseURL, sys.BaseURL)
+			require.Equal(t, tt.wantEventTargetURL, sys.EventTargetURL)
+			require.Equal(t, tt.wantPort, sys.Callback.Port)
+		})
+	}
+}
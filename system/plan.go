@@ -0,0 +1,276 @@
+package system
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// Step is a single request of the execution plan, either a setup or the request of a test case
+type Step struct {
+	TestCase string
+	// Name is the name of the setup, or "request" for the request of the test case
+	Name     string
+	Method   immune.Method
+	Endpoint string
+	// Consumes are the variables referenced by the url & body of the request
+	Consumes []string
+	// Produces are the variables stored from the response
+	Produces []string
+}
+
+// Plan is the resolved execution order of a system
+type Plan struct {
+	Steps []Step
+	// Skipped are the test cases that are not run
+	Skipped []immune.SkippedTestCase
+	// Callbacks describes the callbacks expected by each test case, by test case name
+	Callbacks map[string]string
+	// Truncation is the database truncated before the run and after each
+	// test case, it is empty if nothing is truncated
+	Truncation string
+	// CallbackServer describes where callbacks are received, empty if none is needed
+	CallbackServer string
+}
+
+const requestStep = "request"
+
+// Plan resolves the execution order of the system without sending any request. It
+// expects the system to have been cleaned, see Clean
+func (s *System) Plan() (*Plan, error) {
+	p := &Plan{
+		Skipped:    s.Skipped(),
+		Callbacks:  map[string]string{},
+		Truncation: s.describeTruncation(),
+	}
+
+	if s.NeedsCallbackServer() {
+		p.CallbackServer = s.redact(s.describeCallbackServer())
+	}
+
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+		if tc.Skip {
+			continue
+		}
+
+		for _, setupName := range tc.Setup {
+			setupTC, err := s.setupTestCase(setupName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "test_case %s", tc.Name)
+			}
+
+			p.Steps = append(p.Steps, Step{
+				TestCase: tc.Name,
+				Name:     setupName,
+				Method:   setupTC.HTTPMethod,
+				Endpoint: s.redact(setupTC.Endpoint),
+				Consumes: union(immune.VariableRefs(setupTC.Endpoint), setupTC.RequestBody.VariableRefs()),
+				Produces: sortedKeys(setupTC.StoreResponseVariables),
+			})
+		}
+
+		consumes, err := testCaseRefs(tc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "test_case %s", tc.Name)
+		}
+
+		p.Steps = append(p.Steps, Step{
+			TestCase: tc.Name,
+			Name:     requestStep,
+			Method:   tc.HTTPMethod,
+			Endpoint: s.redact(tc.Endpoint),
+			Consumes: consumes,
+			Produces: sortedKeys(tc.StoreResponseHeaders),
+		})
+
+		p.Callbacks[tc.Name] = s.describeCallback(tc)
+	}
+
+	return p, nil
+}
+
+// testCaseRefs returns the variables referenced by the url and body of the request of tc
+func testCaseRefs(tc *immune.TestCase) ([]string, error) {
+	raw := tc.RawBody
+	if tc.BodyFile != "" {
+		b, err := ioutil.ReadFile(tc.BodyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read body file")
+		}
+		raw = string(b)
+	}
+
+	return union(immune.VariableRefs(tc.Endpoint), tc.RequestBody.VariableRefs(), immune.VariableRefs(raw)), nil
+}
+
+// Write prints the plan to w, grouped by test case
+func (p *Plan) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if p.Truncation != "" {
+		fmt.Fprintf(tw, "database:\t%s is dropped before the run and after each test case\n", p.Truncation)
+	} else {
+		fmt.Fprintf(tw, "database:\tnothing is truncated\n")
+	}
+	if p.CallbackServer != "" {
+		fmt.Fprintf(tw, "callbacks:\t%s\n", p.CallbackServer)
+	}
+
+	testCase := ""
+	for i, step := range p.Steps {
+		if step.TestCase != testCase {
+			testCase = step.TestCase
+			fmt.Fprintf(tw, "\ntest_case %s\n", testCase)
+			fmt.Fprintf(tw, "  STEP\tREQUEST\tCONSUMES\tPRODUCES\n")
+		}
+
+		fmt.Fprintf(tw, "  %s\t%s %s\t%s\t%s\n", step.Name, step.Method, step.Endpoint, list(step.Consumes), list(step.Produces))
+
+		last := i == len(p.Steps)-1 || p.Steps[i+1].TestCase != testCase
+		if !last {
+			continue
+		}
+
+		if cb := p.Callbacks[testCase]; cb != "" {
+			fmt.Fprintf(tw, "  callbacks\t%s\n", cb)
+		}
+		if p.Truncation != "" {
+			fmt.Fprintf(tw, "  truncate\tdrop %s\n", p.Truncation)
+		}
+	}
+
+	if len(p.Skipped) > 0 {
+		fmt.Fprintf(tw, "\nskipped\n")
+		for _, sk := range p.Skipped {
+			fmt.Fprintf(tw, "  %s\t%s\n", sk.TestCase, sk.Reason)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// redact masks the secrets in str, the redactor is only set by NewSystem
+func (s *System) redact(str string) string {
+	if s.Redactor == nil {
+		return str
+	}
+	return s.Redactor.String(str)
+}
+
+func (s *System) describeTruncation() string {
+	switch s.Database.Type {
+	case "mongo":
+		u, err := url.Parse(s.Database.Dsn)
+		if err != nil {
+			return "mongo database of an invalid dsn"
+		}
+		// only the database name, the dsn may contain credentials
+		return fmt.Sprintf("mongo database %s", strings.TrimPrefix(u.Path, "/"))
+	default:
+		return ""
+	}
+}
+
+func (s *System) describeCallbackServer() string {
+	if s.Callback.RemoteURL != "" {
+		return fmt.Sprintf("streamed from the remote callback server %s", s.Callback.RemoteURL)
+	}
+
+	receivers := s.Callback.AllReceivers()
+	descriptions := make([]string, 0, len(receivers))
+	for _, r := range receivers {
+		port := "a free port"
+		if r.Port != 0 {
+			port = fmt.Sprintf("port %d", r.Port)
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s on %s, route %s", r.Name, port, r.Route))
+	}
+	return "received by " + strings.Join(descriptions, "; ")
+}
+
+// describeCallback describes the callbacks tc expects, it is empty if tc expects none
+func (s *System) describeCallback(tc *immune.TestCase) string {
+	cb := &tc.Callback
+	if !cb.Enabled {
+		return ""
+	}
+
+	var desc string
+	lower, upper := cb.Bounds()
+	switch {
+	case cb.ExpectsNone():
+		window := cb.QuietWindowSeconds
+		if window == 0 {
+			window = s.Callback.MaxWaitSeconds
+		}
+		return fmt.Sprintf("none within a %ds quiet window", window)
+	case cb.Times > 0:
+		desc = fmt.Sprintf("exactly %d", cb.Times)
+	case upper > 0 && lower > 0:
+		desc = fmt.Sprintf("between %d and %d", lower, upper)
+	case upper > 0:
+		desc = fmt.Sprintf("at most %d", upper)
+	default:
+		desc = fmt.Sprintf("at least %d", lower)
+	}
+
+	desc += fmt.Sprintf(" within %ds", s.Callback.MaxWaitSeconds)
+	if tc.Repeat > 1 {
+		desc += fmt.Sprintf(" for each of %d events", tc.Repeat)
+	}
+
+	if len(cb.Receivers) > 0 {
+		desc += " at " + strings.Join(cb.Receivers, ", ")
+	}
+
+	location := cb.IDLocation
+	if location == "" {
+		location = s.Callback.IDLocation
+	}
+	if loc, err := immune.ParseCallbackIDLocation(location); err == nil {
+		desc += ", callback id at " + loc.String()
+	}
+
+	return desc
+}
+
+// union returns the distinct names of lists, sorted
+func union(lists ...[]string) []string {
+	set := map[string]bool{}
+	for _, l := range lists {
+		for _, name := range l {
+			set[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m immune.S) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func list(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
@@ -3,12 +3,12 @@ package system
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/frain-dev/immune"
 	"github.com/frain-dev/immune/callback"
 	"github.com/frain-dev/immune/database"
 	"github.com/frain-dev/immune/exec"
-	"github.com/frain-dev/immune/funcs"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -18,12 +18,34 @@ import (
 // and then the test cases, a callback server will be started if needed.
 func (s *System) Run(ctx context.Context) error {
 	var cs immune.CallbackServer
-	var err error
 
-	if s.needsCallback {
-		cs, err = callback.NewServer(&s.Callback)
-		if err != nil {
-			return errors.Wrap(err, "failed to initialize new callback server")
+	// fail before any resource is created rather than on the first undefined variable
+	issues, err := s.AnalyseVariables()
+	if err != nil {
+		return err
+	}
+
+	var undefined []string
+	for _, issue := range issues {
+		if issue.IsError() {
+			undefined = append(undefined, issue.Error())
+			continue
+		}
+		log.Warn(issue)
+	}
+
+	if len(undefined) > 0 {
+		return errors.Errorf("variable flow analysis failed: %s", strings.Join(undefined, "; "))
+	}
+
+	if s.NeedsCallbackServer() {
+		if s.Callback.RemoteURL != "" {
+			cs = callback.NewRemoteServer(&s.Callback, http.DefaultClient)
+		} else {
+			cs, err = callback.NewServer(&s.Callback, s.Redactor)
+			if err != nil {
+				return errors.Wrap(err, "failed to initialize new callback server")
+			}
 		}
 
 		err = cs.Start(ctx)
@@ -32,6 +54,7 @@ func (s *System) Run(ctx context.Context) error {
 		}
 
 		defer cs.Stop()
+		s.deriveEventTargetURLs(cs)
 	}
 
 	truncator, err := database.NewTruncator(&s.Database)
@@ -48,6 +71,7 @@ func (s *System) Run(ctx context.Context) error {
 		return uuid.New().String()
 	}
 	ex := exec.NewExecutor(cs, http.DefaultClient, s.Variables, s.Callback.MaxWaitSeconds, s.BaseURL, s.Callback.IDLocation, truncator, idFn)
+	defer reportMissingCallbacks(ex.MissingCallbacks)
 
 	//log.Info("starting execution of setup test cases")
 	//for i := range s.SetupTestCases {
@@ -59,42 +83,107 @@ func (s *System) Run(ctx context.Context) error {
 	//log.Info("finished execution of setup test cases")
 
 	log.Info("starting execution of test cases")
+	ex.SetTracer(s.Tracer)
+	passed := 0
 	for i := range s.TestCases {
 		tc := &s.TestCases[i]
-		for _, setupName := range tc.Setup {
-			switch setupName {
-			case "setup_group":
-				err = funcs.SetupGroup(ctx, ex)
-				if err != nil {
-					return err
-				}
-			case "setup_app":
-				err = funcs.SetupApp(ctx, ex)
-				if err != nil {
-					return err
-				}
-			case "setup_endpoint":
-				err = funcs.SetupAppEndpoint(ctx, s.EventTargetURL, ex)
-				if err != nil {
-					return err
-				}
-			case "setup_event":
-				err = funcs.SetupEvent(ctx, ex)
-				if err != nil {
-					return err
-				}
-			default:
-				return errors.Errorf("unknown setup %s, in test case %s", setupName, tc.Name)
-			}
+		if tc.Skip {
+			log.WithField(immune.LogFieldTestCase, tc.Name).Infof("test_case %s skipped: %s", tc.Name, skipReason(tc))
+			continue
 		}
-		err = ex.ExecuteTestCase(ctx, tc)
+
+		err = s.Tracer.StartTestCase(tc.Name)
+		if err != nil {
+			return err
+		}
+
+		err = s.runTestCase(ctx, ex, tc)
+		s.Tracer.FinishTestCase(tc.Name, err)
 		if err != nil {
 			return err
 		}
-		log.Infof("test_case %s passed", tc.Name)
+		log.WithField(immune.LogFieldTestCase, tc.Name).Infof("test_case %s passed", tc.Name)
+		passed++
 	}
 
 	log.Info("finished execution of test cases")
+	reportSkipped(passed, s.Skipped())
 
 	return nil
 }
+
+// runTestCase executes the setups of tc, then tc, logging the step that fails with its fields
+func (s *System) runTestCase(ctx context.Context, ex *exec.Executor, tc *immune.TestCase) error {
+	for _, setupName := range tc.Setup {
+		setupTC, err := s.setupTestCase(setupName)
+		if err != nil {
+			return errors.Wrapf(err, "test case %s", tc.Name)
+		}
+
+		setupLog := log.WithFields(log.Fields{immune.LogFieldTestCase: tc.Name, immune.LogFieldSetupStep: setupName})
+		setupLog.Debugf("executing setup_test_case %s", setupName)
+
+		err = ex.ExecuteSetupTestCase(ctx, setupTC)
+		if err != nil {
+			setupLog.WithError(err).Errorf("setup_test_case %s failed", setupName)
+			return err
+		}
+	}
+
+	tcLog := log.WithField(immune.LogFieldTestCase, tc.Name)
+	tcLog.Debugf("executing test_case %s", tc.Name)
+
+	err := ex.ExecuteTestCase(ctx, tc)
+	if err != nil {
+		tcLog.WithError(err).Errorf("test_case %s failed", tc.Name)
+	}
+	return err
+}
+
+// reportMissingCallbacks logs every callback that did not arrive before the deadline
+func reportMissingCallbacks(missingFn func() []immune.MissingCallback) {
+	missing := missingFn()
+	if len(missing) == 0 {
+		return
+	}
+
+	log.Errorf("%d test case(s) did not receive all their callbacks before max callback wait seconds elapsed", len(missing))
+	for _, m := range missing {
+		log.WithFields(log.Fields{immune.LogFieldTestCase: m.TestCase, immune.LogFieldCallbackID: m.CallbackID}).
+			Errorf("missing callback: test_case: %s, callback_id: %s, wants %d callbacks, got %d callbacks", m.TestCase, m.CallbackID, m.Want, m.Got)
+	}
+}
+
+// reportSkipped logs the number of test cases that passed & every test case that was skipped
+func reportSkipped(passed int, skipped []immune.SkippedTestCase) {
+	log.Infof("%d test case(s) passed, %d skipped", passed, len(skipped))
+	for _, sk := range skipped {
+		log.WithField(immune.LogFieldTestCase, sk.TestCase).Infof("skipped test_case: %s, reason: %s", sk.TestCase, sk.Reason)
+	}
+}
+
+// deriveEventTargetURLs sets every empty event target url to the url of its receiver on the
+// public host of the callback server, using the port the receiver is bound to
+func (s *System) deriveEventTargetURLs(cs immune.CallbackServer) {
+	host := s.Callback.PublicHost
+	if host == "" {
+		return
+	}
+
+	receivers := s.Callback.AllReceivers()
+	if port := cs.Port(immune.DefaultReceiverName); s.EventTargetURL == "" && port != 0 {
+		s.EventTargetURL = receivers[0].URL(host, port)
+		log.Infof("using event_target_url %s", s.EventTargetURL)
+	}
+
+	for i := range s.Callback.Receivers {
+		r := &s.Callback.Receivers[i]
+		port := cs.Port(r.Name)
+		if r.EventTargetURL != "" || port == 0 { // the ports of remote callback servers are unknown
+			continue
+		}
+
+		r.EventTargetURL = receivers[i+1].URL(host, port)
+		log.Infof("using event_target_url %s for callback receiver %s", r.EventTargetURL, r.Name)
+	}
+}
@@ -0,0 +1,57 @@
+package system
+
+import (
+	"reflect"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/schema"
+)
+
+// SchemaVersion is the version of the json schema of the configuration,
+// it is bumped whenever a change to the configuration breaks existing files
+const SchemaVersion = "v1"
+
+// SchemaID is where the json schema of the configuration is published, a configuration can
+// point editors at it with "$schema", see the schema directory and the schema command
+const SchemaID = "https://raw.githubusercontent.com/frain-dev/immune/main/schema/immune." + SchemaVersion + ".schema.json"
+
+// Schema returns the json schema of the configuration, generated from System
+func Schema() *schema.Schema {
+	g := &schema.Generator{
+		Overrides: map[reflect.Type]schema.Override{
+			reflect.TypeOf(immune.Method("")): schema.Enum(
+				immune.MethodPost.String(), immune.MethodPUT.String(), immune.MethodGet.String(),
+				immune.MethodPatch.String(), immune.MethodHead.String(), immune.MethodDelete.String(),
+				immune.MethodConnect.String(), immune.MethodOptions.String(), immune.MethodTrace.String(),
+			),
+			reflect.TypeOf(immune.BodyType("")): schema.Enum(
+				immune.BodyTypeJSON.String(), immune.BodyTypeForm.String(), immune.BodyTypeMultipart.String(),
+				immune.BodyTypeRaw.String(), immune.BodyTypeXML.String(),
+			),
+			reflect.TypeOf(immune.RetryStrategy("")): schema.Enum(
+				string(immune.RetryStrategyConstant), string(immune.RetryStrategyExponential),
+			),
+			// a step can be written as just its status code, see CallbackResponseStep.UnmarshalJSON
+			reflect.TypeOf(immune.CallbackResponseStep{}): func(g *schema.Generator, t reflect.Type) *schema.Schema {
+				return &schema.Schema{OneOf: []*schema.Schema{{Type: "integer"}, g.StructSchema(t)}}
+			},
+		},
+	}
+
+	// any string can be a secret reference, see secretResolver
+	g.Strings = &schema.Schema{OneOf: []*schema.Schema{{Type: "string"}, {Ref: "#/definitions/SecretRef"}}}
+	g.Definitions = map[string]*schema.Schema{
+		"SecretRef": {
+			Description: "reads a secret from an environment variable or a file, the secret is redacted from the output",
+			OneOf: []*schema.Schema{
+				{Type: "object", Properties: map[string]*schema.Schema{fromEnvField: {Type: "string"}}, Required: []string{fromEnvField}, AdditionalProperties: false},
+				{Type: "object", Properties: map[string]*schema.Schema{fromFileField: {Type: "string"}}, Required: []string{fromFileField}, AdditionalProperties: false},
+			},
+		},
+	}
+
+	s := g.Generate(&System{})
+	s.ID = SchemaID
+	s.Title = "immune configuration " + SchemaVersion
+	return s
+}
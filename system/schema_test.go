@@ -0,0 +1,24 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the committed schema must be regenerated with the schema command whenever the configuration changes
+func TestSchema_MatchesCommittedSchema(t *testing.T) {
+	committed, err := ioutil.ReadFile(filepath.Join("..", "schema", "immune."+SchemaVersion+".schema.json"))
+	require.NoError(t, err)
+
+	var generated bytes.Buffer
+	enc := json.NewEncoder(&generated)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(Schema()))
+
+	require.Equal(t, string(committed), generated.String(), "run: go run ./cmd schema > schema/immune.%s.schema.json", SchemaVersion)
+}
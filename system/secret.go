@@ -0,0 +1,159 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/frain-dev/immune/redact"
+	"github.com/pkg/errors"
+)
+
+const (
+	fromEnvField  = "from_env"
+	fromFileField = "from_file"
+)
+
+// secretResolver replaces the secret references of a config document with their values, a secret
+// reference is an object of a single field, either {"from_env": "MONGO_DSN"} to read the secret
+// from an environment variable, or {"from_file": "mongo_dsn.txt"} to read it from a file whose
+// path is relative to the config file. Secrets are redacted from the output of immune
+type secretResolver struct {
+	name      string
+	dir       string
+	positions map[string]string
+	secrets   []string
+	// profileErrors records the first error of each profile rather than failing, the
+	// profiles which are not selected may reference secrets missing where immune runs
+	profileErrors map[string]error
+}
+
+// resolve replaces the secret references within v, the value at path
+func (r *secretResolver) resolve(v interface{}, path string) error {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			itemPath := joinPath(path, key)
+			secret, ok, err := r.secretOf(item, itemPath)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				value[key] = secret
+				continue
+			}
+
+			err = r.resolve(item, itemPath)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			secret, ok, err := r.secretOf(item, itemPath)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				value[i] = secret
+				continue
+			}
+
+			err = r.resolve(item, itemPath)
+			if err != nil {
+				return err
+			}
+		}
+	case []map[string]interface{}: // arrays of tables in toml
+		for i, item := range value {
+			err := r.resolve(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// secretOf returns the value of v if it is a secret reference
+func (r *secretResolver) secretOf(v interface{}, path string) (string, bool, error) {
+	ref, ok := v.(map[string]interface{})
+	if !ok || len(ref) != 1 {
+		return "", false, nil
+	}
+
+	var secret string
+	if name, ok := ref[fromEnvField].(string); ok {
+		secret, ok = os.LookupEnv(name)
+		if !ok {
+			return r.fail(path, errors.Errorf("%s: secret environment variable %s is not set", r.locate(path), name))
+		}
+	} else if file, ok := ref[fromFileField].(string); ok {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(r.dir, file)
+		}
+
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return r.fail(path, errors.Wrapf(err, "%s: failed to read secret file", r.locate(path)))
+		}
+		// files usually end with a new line, which is not part of the secret
+		secret = strings.TrimRight(string(b), "\r\n")
+	} else {
+		return "", false, nil
+	}
+
+	r.secrets = append(r.secrets, secret)
+	return secret, true, nil
+}
+
+// fail returns err, unless path is within a profile, then the error is recorded for the profile
+func (r *secretResolver) fail(path string, err error) (string, bool, error) {
+	prefix := profilesField + "."
+	if !strings.HasPrefix(path, prefix) {
+		return "", false, err
+	}
+
+	profile := strings.SplitN(strings.TrimPrefix(path, prefix), ".", 2)[0]
+	if _, ok := r.profileErrors[profile]; !ok {
+		r.profileErrors[profile] = err
+	}
+	return "", true, nil
+}
+
+func (r *secretResolver) locate(path string) string {
+	if pos, ok := r.positions[path]; ok {
+		return pos
+	}
+	return fmt.Sprintf("%s: %s", r.name, path)
+}
+
+// sensitiveValues returns the string values of the sensitive fields of a config document, like
+// a password in a request body, so they are masked wherever they appear, not only in their field
+func sensitiveValues(r *redact.Redactor, v interface{}) []string {
+	var values []string
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if s, ok := item.(string); ok && r.IsSensitive(key) {
+				values = append(values, s)
+				continue
+			}
+			values = append(values, sensitiveValues(r, item)...)
+		}
+	case []interface{}:
+		for _, item := range value {
+			values = append(values, sensitiveValues(r, item)...)
+		}
+	case []map[string]interface{}: // arrays of tables in toml
+		for _, item := range value {
+			values = append(values, sensitiveValues(r, item)...)
+		}
+	}
+	return values
+}
@@ -0,0 +1,128 @@
+package system
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Selection picks the test cases to run, the test cases it does not pick are skipped
+type Selection struct {
+	// Run is a regular expression the names of the test cases must match
+	Run string
+	// Tags are the tags of which the test cases must have at least one
+	Tags []string
+	// ExcludeTags are the tags of which the test cases must have none
+	ExcludeTags []string
+	// Skip are the names of the test cases to skip
+	Skip []string
+}
+
+const defaultSkipReason = "skip is set"
+
+// Select skips the test cases sel does not pick. The test cases that set skip stay skipped
+// with their own reason, the others get the reason sel does not pick them
+func (s *System) Select(sel Selection) error {
+	var run *regexp.Regexp
+	if sel.Run != "" {
+		var err error
+		run, err = regexp.Compile(sel.Run)
+		if err != nil {
+			return errors.Wrap(err, "invalid --run")
+		}
+	}
+
+	names := map[string]bool{}
+	for i := range s.TestCases {
+		names[s.TestCases[i].Name] = true
+	}
+
+	var unknown []string
+	for _, name := range sel.Skip {
+		if !names[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return errors.Errorf("--skip: unknown test cases: %s", strings.Join(unknown, ", "))
+	}
+
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+		if tc.Skip {
+			continue
+		}
+
+		reason := selectReason(tc, sel, run)
+		if reason != "" {
+			tc.Skip, tc.SkipReason = true, reason
+		}
+	}
+
+	if len(s.TestCases) > 0 && len(s.Skipped()) == len(s.TestCases) {
+		log.Warn("every test case is skipped")
+	}
+	return nil
+}
+
+// selectReason returns why sel does not pick tc, it is empty if sel picks tc
+func selectReason(tc *immune.TestCase, sel Selection, run *regexp.Regexp) string {
+	for _, name := range sel.Skip {
+		if name == tc.Name {
+			return "skipped by --skip"
+		}
+	}
+
+	if run != nil && !run.MatchString(tc.Name) {
+		return fmt.Sprintf("does not match --run %s", run)
+	}
+
+	if len(sel.Tags) > 0 {
+		tagged := false
+		for _, tag := range sel.Tags {
+			if tc.HasTag(tag) {
+				tagged = true
+				break
+			}
+		}
+
+		if !tagged {
+			return fmt.Sprintf("has none of the tags %s", strings.Join(sel.Tags, ", "))
+		}
+	}
+
+	for _, tag := range sel.ExcludeTags {
+		if tc.HasTag(tag) {
+			return fmt.Sprintf("has the excluded tag %s", tag)
+		}
+	}
+
+	return ""
+}
+
+// Skipped returns the test cases that are skipped, in order
+func (s *System) Skipped() []immune.SkippedTestCase {
+	var skipped []immune.SkippedTestCase
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+		if !tc.Skip {
+			continue
+		}
+
+		skipped = append(skipped, immune.SkippedTestCase{TestCase: tc.Name, Reason: skipReason(tc)})
+	}
+	return skipped
+}
+
+// skipReason returns why tc is skipped
+func skipReason(tc *immune.TestCase) string {
+	if tc.SkipReason == "" {
+		return defaultSkipReason
+	}
+	return tc.SkipReason
+}
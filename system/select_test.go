@@ -0,0 +1,154 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func newSelectionSystem() *System {
+	return &System{
+		BaseURL: "http://localhost:5005/api/v1",
+		TestCases: []immune.TestCase{
+			{Name: "smoke_create", Tags: []string{"smoke"}, StatusCode: 201, HTTPMethod: "POST", Endpoint: "/events", Callback: immune.Callback{Enabled: true, Times: 1}},
+			{Name: "slow_list", Tags: []string{"slow", "list"}, StatusCode: 200, HTTPMethod: "GET", Endpoint: "/events"},
+			{Name: "flaky", Tags: []string{"smoke"}, Skip: true, SkipReason: "upstream bug", StatusCode: 200, HTTPMethod: "GET", Endpoint: "/events"},
+			{Name: "plain", StatusCode: 200, HTTPMethod: "GET", Endpoint: "/events"},
+			{Name: "no_reason", Skip: true, StatusCode: 200, HTTPMethod: "GET", Endpoint: "/events"},
+		},
+	}
+}
+
+func TestSystem_Select(t *testing.T) {
+	flaky := immune.SkippedTestCase{TestCase: "flaky", Reason: "upstream bug"}
+	noReason := immune.SkippedTestCase{TestCase: "no_reason", Reason: "skip is set"}
+
+	tests := []struct {
+		name        string
+		sel         Selection
+		wantSkipped []immune.SkippedTestCase
+		wantErr     bool
+		wantErrMsg  string
+	}{
+		{
+			name:        "should_skip_test_cases_setting_skip",
+			wantSkipped: []immune.SkippedTestCase{flaky, noReason},
+		},
+		{
+			name: "should_skip_test_cases_not_matching_run",
+			sel:  Selection{Run: "^smoke|plain"},
+			wantSkipped: []immune.SkippedTestCase{
+				{TestCase: "slow_list", Reason: "does not match --run ^smoke|plain"},
+				flaky,
+				noReason,
+			},
+		},
+		{
+			name: "should_skip_test_cases_without_tags",
+			sel:  Selection{Tags: []string{"smoke", "list"}},
+			wantSkipped: []immune.SkippedTestCase{
+				flaky,
+				{TestCase: "plain", Reason: "has none of the tags smoke, list"},
+				noReason,
+			},
+		},
+		{
+			name: "should_skip_test_cases_with_excluded_tags",
+			sel:  Selection{ExcludeTags: []string{"list"}},
+			wantSkipped: []immune.SkippedTestCase{
+				{TestCase: "slow_list", Reason: "has the excluded tag list"},
+				flaky,
+				noReason,
+			},
+		},
+		{
+			name: "should_skip_named_test_cases",
+			sel:  Selection{Skip: []string{"plain", "flaky"}},
+			wantSkipped: []immune.SkippedTestCase{
+				flaky,
+				{TestCase: "plain", Reason: "skipped by --skip"},
+				noReason,
+			},
+		},
+		{
+			name: "should_give_skip_precedence_over_run",
+			sel:  Selection{Run: "plain", Skip: []string{"plain"}},
+			wantSkipped: []immune.SkippedTestCase{
+				{TestCase: "smoke_create", Reason: "does not match --run plain"},
+				{TestCase: "slow_list", Reason: "does not match --run plain"},
+				flaky,
+				{TestCase: "plain", Reason: "skipped by --skip"},
+				noReason,
+			},
+		},
+		{
+			name:       "should_error_for_unknown_skip",
+			sel:        Selection{Skip: []string{"plain", "nope", "missing"}},
+			wantErr:    true,
+			wantErrMsg: "--skip: unknown test cases: nope, missing",
+		},
+		{
+			name:       "should_error_for_invalid_run",
+			sel:        Selection{Run: "smoke["},
+			wantErr:    true,
+			wantErrMsg: "invalid --run: error parsing regexp: missing closing ]: `[`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := newSelectionSystem()
+
+			err := sys.Select(tt.sel)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				require.Equal(t, []immune.SkippedTestCase{flaky, noReason}, sys.Skipped())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSkipped, sys.Skipped())
+		})
+	}
+}
+
+func TestSystem_NeedsCallbackServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		sel          Selection
+		cleanFirst   bool
+		wantCallback bool
+	}{
+		{
+			name:         "should_need_callback_server_for_selected_callback_test_case",
+			sel:          Selection{Tags: []string{"smoke"}},
+			wantCallback: true,
+		},
+		{
+			name: "should_not_need_callback_server_for_skipped_callback_test_case",
+			sel:  Selection{Skip: []string{"smoke_create"}},
+		},
+		{
+			name:       "should_not_need_callback_server_when_selected_after_clean",
+			sel:        Selection{ExcludeTags: []string{"smoke"}},
+			cleanFirst: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := newSelectionSystem()
+			if tt.cleanFirst {
+				require.NoError(t, sys.Clean())
+			}
+
+			require.NoError(t, sys.Select(tt.sel))
+			require.NoError(t, sys.Clean())
+			require.Equal(t, tt.wantCallback, sys.NeedsCallbackServer())
+
+			p, err := sys.Plan()
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCallback, p.CallbackServer != "")
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package system
+
+import (
+	"strings"
+
+	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/funcs"
+	"github.com/pkg/errors"
+)
+
+// setupEndpointPrefix names the receiver whose event target url is used by setup_endpoint
+const setupEndpointPrefix = "setup_endpoint:"
+
+// setupTestCase returns the setup test case for the setup named in a test case
+func (s *System) setupTestCase(setupName string) (*immune.SetupTestCase, error) {
+	switch setupName {
+	case "setup_group":
+		return funcs.GroupSetup()
+	case "setup_app":
+		return funcs.AppSetup()
+	case "setup_endpoint":
+		return funcs.AppEndpointSetup(s.EventTargetURL)
+	case "setup_event":
+		return funcs.EventSetup()
+	default:
+		target, ok := s.receiverTargetURL(setupName)
+		if !ok {
+			return nil, errors.Errorf("unknown setup %s", setupName)
+		}
+		return funcs.AppEndpointSetup(target)
+	}
+}
+
+// receiverTargetURL returns the event target url of the receiver named
+// in a setup of the form setup_endpoint:{receiver_name}
+func (s *System) receiverTargetURL(setupName string) (string, bool) {
+	name := strings.TrimPrefix(setupName, setupEndpointPrefix)
+	if name == setupName {
+		return "", false
+	}
+
+	for _, r := range s.Callback.Receivers {
+		if r.Name == name {
+			return r.EventTargetURL, true
+		}
+	}
+
+	return "", false
+}
@@ -6,15 +6,21 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/frain-dev/immune"
+	"github.com/frain-dev/immune/callback"
+	"github.com/frain-dev/immune/exec"
+	"github.com/frain-dev/immune/redact"
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
 )
 
 // System represents the entire suite to be run against an API
 type System struct {
+	// Schema is the json schema of the configuration, for editors, see SchemaID
+	Schema         string                       `json:"$schema"`
 	BaseURL        string                       `json:"base_url"`
 	EventTargetURL string                       `json:"event_target_url" envconfig:"IMMUNE_EVENT_TARGET_URL"`
 	Database       immune.Database              `json:"database"`
@@ -22,22 +28,50 @@ type System struct {
 	Variables      *immune.VariableMap          `json:"-"`
 	SetupTestCases []immune.SetupTestCase       `json:"setup_test_cases"`
 	TestCases      []immune.TestCase            `json:"test_cases"`
-	needsCallback  bool
+	// Include are the files, or globs, the test cases & setup test cases are also pulled from.
+	// Included files can only set test_cases, setup_test_cases and include
+	Include []string `json:"include"`
+	// Profiles are overlaid on the rest of the config when selected by name, they can
+	// set any field but profiles, include, test_cases & setup_test_cases, see Options
+	Profiles map[string]*System `json:"profiles"`
+	// RedactFields are the names of sensitive fields in addition to redact.DefaultFields,
+	// the values of headers, json fields & query parameters containing them are masked
+	RedactFields []string `json:"redact_fields"`
+	// Redactor masks the secrets of the system in logs, errors & reports
+	Redactor *redact.Redactor `json:"-"`
+	// Tracer traces the requests, responses & callbacks of Run, nil traces nothing
+	Tracer *exec.Tracer `json:"-"`
 }
 
-func NewSystem(filePath string) (*System, error) {
-	f, err := os.Open(filePath)
+// NewSystem loads the system from the config files in filePaths, later files take precedence
+// over earlier ones, see loadConfig. Then, in increasing order of precedence, the profile of
+// opts is overlaid, the environment overrides are applied, and finally the overrides of opts
+func NewSystem(opts Options, filePaths ...string) (*System, error) {
+	cfg, err := loadConfig(filePaths)
 	if err != nil {
 		return nil, err
 	}
 
-	sys := &System{}
+	err = applyProfile(cfg, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
 
-	err = json.NewDecoder(f).Decode(sys)
+	b, err := json.Marshal(cfg.doc)
 	if err != nil {
 		return nil, err
 	}
 
+	sys := &System{}
+	err = json.Unmarshal(b, sys)
+	if err != nil {
+		return nil, err
+	}
+
+	if sys.Schema != "" && sys.Schema != SchemaID {
+		log.Warnf("$schema %s is not the schema of this version of immune: %s", sys.Schema, SchemaID)
+	}
+
 	envOverride := &System{}
 	err = envconfig.Process("IMMUNE", envOverride)
 	if err != nil {
@@ -46,6 +80,23 @@ func NewSystem(filePath string) (*System, error) {
 
 	processOverride(sys, envOverride)
 
+	for _, set := range opts.Set {
+		err = applySet(sys, set)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sys.Redactor = redact.New(sys.RedactFields...)
+	sys.Redactor.AddValues(cfg.secrets...)
+	sys.Redactor.AddValues(sensitiveValues(sys.Redactor, cfg.doc)...)
+	sys.Redactor.AddValues(sys.Callback.LinkToken)
+
+	if u, err := url.Parse(sys.Database.Dsn); err == nil {
+		password, _ := u.User.Password()
+		sys.Redactor.AddValues(password)
+	}
+
 	sys.Variables = &immune.VariableMap{VariableToValue: immune.M{}}
 	return sys, nil
 }
@@ -66,67 +117,570 @@ func processOverride(sys, override *System) {
 	if override.Callback.SSLCertFile != "" {
 		sys.Callback.SSLCertFile = override.Callback.SSLCertFile
 	}
+
+	if override.Callback.PublicHost != "" {
+		sys.Callback.PublicHost = override.Callback.PublicHost
+	}
+
+	if override.Callback.RemoteURL != "" {
+		sys.Callback.RemoteURL = override.Callback.RemoteURL
+	}
+
+	if override.Callback.LinkToken != "" {
+		sys.Callback.LinkToken = override.Callback.LinkToken
+	}
+
+	if override.Callback.LogFile != "" {
+		sys.Callback.LogFile = override.Callback.LogFile
+	}
+
+	if _, ok := os.LookupEnv("IMMUNE_AUTO_TLS"); ok {
+		sys.Callback.AutoTLS = override.Callback.AutoTLS
+	}
+
+	if override.Callback.CACertOutFile != "" {
+		sys.Callback.CACertOutFile = override.Callback.CACertOutFile
+	}
+
+	if override.Callback.ClientCertOutFile != "" {
+		sys.Callback.ClientCertOutFile = override.Callback.ClientCertOutFile
+	}
+
+	if override.Callback.ClientKeyOutFile != "" {
+		sys.Callback.ClientKeyOutFile = override.Callback.ClientKeyOutFile
+	}
+
+	if _, ok := os.LookupEnv("IMMUNE_VERIFY_CLIENT_CERT"); ok {
+		sys.Callback.VerifyClientCert = override.Callback.VerifyClientCert
+	}
+
+	if override.Callback.ClientCAFile != "" {
+		sys.Callback.ClientCAFile = override.Callback.ClientCAFile
+	}
 }
 
 const maxCallbackWait = 5
 
-// Clean validates the System's data
+// Clean applies the defaults of the System's data and validates it, it returns
+// the first problem found, see Validate for all of them
 func (s *System) Clean() error {
+	s.applyDefaults()
+
+	problems := s.check()
+	if len(problems) > 0 {
+		return problems[0]
+	}
+
+	return nil
+}
+
+// applyDefaults sets the fields the System's data leaves empty to their defaults
+func (s *System) applyDefaults() {
+	s.applyCallbackDefaults()
+
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+
+		if tc.BodyType == "" {
+			tc.BodyType = immune.BodyTypeJSON
+		}
+
+		if tc.Callback.Enabled {
+			applyRetryDefaults(&tc.Callback)
+		}
+	}
+}
+
+// check validates the System's data, it returns every problem found. It
+// expects the defaults to be applied, see applyDefaults
+func (s *System) check() []error {
+	var problems []error
+
 	if s.BaseURL == "" {
-		return errors.New("base url cannot be empty")
+		problems = append(problems, errors.New("base url cannot be empty"))
+	} else if _, err := url.Parse(s.BaseURL); err != nil {
+		problems = append(problems, fmt.Errorf("base url is not a vaild url: %v", err))
 	}
 
-	if s.Callback.SSL {
-		if s.Callback.SSLCertFile == "" || s.Callback.SSLKeyFile == "" {
-			return errors.New("both cert_file and key_file are required for ssl")
+	problems = append(problems, s.checkCallback()...)
+
+	for i := range s.TestCases {
+		problems = append(problems, s.checkTestCase(&s.TestCases[i])...)
+	}
+
+	return problems
+}
+
+// checkTestCase validates tc, the problems found are prefixed with its name
+func (s *System) checkTestCase(tc *immune.TestCase) []error {
+	if tc.Name == "" {
+		return []error{errors.New("test case name cannot be empty")}
+	}
+
+	var problems []error
+
+	if len(tc.Endpoint) == 0 {
+		problems = append(problems, errors.New("endpoint cannot be empty"))
+	} else if !strings.HasPrefix(tc.Endpoint, "/") {
+		problems = append(problems, errors.New("endpoint must begin with /"))
+	}
+
+	if !balancedBraces(tc.Endpoint) {
+		problems = append(problems, errors.New("endpoint has a variable reference without a closing }"))
+	}
+
+	if tc.StatusCode < 100 || tc.StatusCode > 511 {
+		problems = append(problems, errors.New("valid range for status_code is 100-511"))
+	}
+
+	if !tc.HTTPMethod.IsValid() {
+		problems = append(problems, fmt.Errorf("invalid method: %s", tc.HTTPMethod.String()))
+	}
+
+	problems = append(problems, checkBody(tc)...)
+	problems = append(problems, checkResponseAssertions(tc)...)
+
+	if tc.Callback.Enabled {
+		problems = append(problems, s.checkTestCaseCallback(tc)...)
+	}
+
+	return prefixed("test_case "+tc.Name, problems)
+}
+
+// checkTestCaseCallback validates the callback expectation of tc
+func (s *System) checkTestCaseCallback(tc *immune.TestCase) []error {
+	cb := &tc.Callback
+
+	var problems []error
+	problems = append(problems, prefixed("callback", checkExpectNone(cb))...)
+	problems = append(problems, prefixed("callback retry", checkRetryExpectation(cb))...)
+	problems = append(problems, checkCallbackCount(cb)...)
+
+	if cb.Ordered && tc.Repeat < 2 {
+		problems = append(problems, errors.New("callback ordered requires repeat to be greater than 1"))
+	}
+
+	if cb.IDLocation != "" {
+		_, err := immune.ParseCallbackIDLocation(cb.IDLocation)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("callback id_location: %v", err))
 		}
+	} else if cb.CreateIDLocation && s.Callback.IDLocation == "" {
+		problems = append(problems, errors.New("callback create_id_location requires an id_location"))
 	}
 
-	_, err := url.Parse(s.BaseURL)
-	if err != nil {
-		return fmt.Errorf("base url is not a vaild url: %v", err)
+	for _, name := range cb.Receivers {
+		if !s.hasReceiver(name) {
+			problems = append(problems, fmt.Errorf("unknown callback receiver %s", name))
+		}
 	}
 
+	return append(problems, prefixed("callback response", checkCallbackResponse(cb.Response))...)
+}
+
+// CleanCallback applies the defaults of the callback configuration and validates it, it
+// returns the first problem found. It is all the receiver command needs
+func (s *System) CleanCallback() error {
+	s.applyCallbackDefaults()
+
+	problems := s.checkCallback()
+	if len(problems) > 0 {
+		return problems[0]
+	}
+
+	return nil
+}
+
+func (s *System) applyCallbackDefaults() {
 	if s.Callback.MaxWaitSeconds == 0 {
 		log.Warnf("max callback wait seconds is 0, using default value of %d seconds", maxCallbackWait)
 		s.Callback.MaxWaitSeconds = maxCallbackWait
 	}
+}
+
+// checkCallback validates the callback configuration, it returns every problem found
+func (s *System) checkCallback() []error {
+	var problems []error
+
+	if s.Callback.SSL && !s.Callback.AutoTLS {
+		if s.Callback.SSLCertFile == "" || s.Callback.SSLKeyFile == "" {
+			problems = append(problems, errors.New("both cert_file and key_file are required for ssl"))
+		}
+	}
+
+	problems = append(problems, checkTLS(&s.Callback)...)
+	problems = append(problems, checkReceivers(&s.Callback)...)
+	problems = append(problems, checkCallbackIDLocations(&s.Callback)...)
+
+	if s.Callback.RemoteURL != "" {
+		u, err := url.Parse(s.Callback.RemoteURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Errorf("callback remote_url %s is not a valid url", s.Callback.RemoteURL))
+		}
+	}
+
+	return problems
+}
+
+// prefixed prefixes the message of every error of errs with prefix
+func prefixed(prefix string, errs []error) []error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	out := make([]error, 0, len(errs))
+	for _, err := range errs {
+		out = append(out, fmt.Errorf("%s: %v", prefix, err))
+	}
+	return out
+}
 
+// balancedBraces reports whether every { in s is followed by a }
+func balancedBraces(s string) bool {
+	return strings.LastIndexByte(s, '{') < strings.LastIndexByte(s, '}') || !strings.Contains(s, "{")
+}
+
+// NeedsCallbackServer reports whether a test case to run expects callbacks, skipped test
+// cases are still checked by Clean, but don't need the callback server. See Select
+func (s *System) NeedsCallbackServer() bool {
 	for i := range s.TestCases {
 		tc := &s.TestCases[i]
+		if tc.Callback.Enabled && !tc.Skip {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBody validates the request body fields of tc against its body type
+func checkBody(tc *immune.TestCase) []error {
+	var problems []error
+
+	if tc.RawBody != "" && tc.BodyFile != "" {
+		problems = append(problems, errors.New("raw_body and body_file cannot be used together"))
+	}
+
+	if len(tc.Files) > 0 && tc.BodyType != immune.BodyTypeMultipart {
+		problems = append(problems, errors.New("files can only be used with the multipart body_type"))
+	}
 
-		if tc.Name == "" {
-			return errors.New("test case name cannot be empty")
+	switch tc.BodyType {
+	case immune.BodyTypeJSON:
+		if tc.RawBody != "" {
+			problems = append(problems, errors.New("raw_body cannot be used with the json body_type, use request_body or body_file"))
+		}
+		if tc.BodyFile != "" && tc.RequestBody != nil {
+			problems = append(problems, errors.New("request_body and body_file cannot be used together"))
+		}
+	case immune.BodyTypeForm, immune.BodyTypeMultipart:
+		if tc.RawBody != "" || tc.BodyFile != "" {
+			problems = append(problems, fmt.Errorf("raw_body and body_file cannot be used with the %s body_type, use request_body", tc.BodyType))
 		}
 
-		if len(tc.Endpoint) == 0 {
-			return fmt.Errorf("test_case %s: endpoint cannot be empty", tc.Name)
+		for _, f := range tc.Files {
+			if f.FieldName == "" || f.Path == "" {
+				problems = append(problems, errors.New("both field_name and path are required for files"))
+				break
+			}
+		}
+	case immune.BodyTypeRaw, immune.BodyTypeXML:
+		if tc.RequestBody != nil {
+			problems = append(problems, fmt.Errorf("request_body cannot be used with the %s body_type, use raw_body or body_file", tc.BodyType))
 		}
+	}
+
+	return problems
+}
+
+// checkResponseAssertions validates the response header, content type and size assertions of tc
+func checkResponseAssertions(tc *immune.TestCase) []error {
+	var problems []error
 
-		if tc.StatusCode < 100 || tc.StatusCode > 511 {
-			return fmt.Errorf("test_case %s: valid range for status_code is 100-511", tc.Name)
+	if tc.MaxResponseBodySize < 0 {
+		problems = append(problems, errors.New("max_response_body_size cannot be negative"))
+	}
+
+	for _, ha := range tc.ResponseHeaders {
+		if ha.Name == "" {
+			problems = append(problems, errors.New("response header name cannot be empty"))
+			continue
 		}
 
-		if !strings.HasPrefix(tc.Endpoint, "/") {
-			return fmt.Errorf("test_case %s: endpoint must begin with /", tc.Name)
+		if ha.Absent && (ha.Value != "" || ha.Regex != "") {
+			problems = append(problems, fmt.Errorf("response header %s: value and regex cannot be used with absent", ha.Name))
 		}
 
-		if !tc.HTTPMethod.IsValid() {
-			return fmt.Errorf("test_case %s: invalid method: %s", tc.Name, tc.HTTPMethod.String())
+		if ha.Regex != "" {
+			_, err := regexp.Compile(ha.Regex)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("response header %s: invalid regex: %v", ha.Name, err))
+			}
 		}
+	}
 
-		if tc.Callback.Enabled {
-			s.needsCallback = true
+	for _, varName := range sortedKeys(tc.StoreResponseHeaders) {
+		if tc.StoreResponseHeaders[varName] == "" {
+			problems = append(problems, fmt.Errorf("store_response_headers: header for variable %s cannot be empty", varName))
+		}
+	}
+
+	return problems
+}
+
+// checkCallbackResponse validates the status codes of the callback response and its script
+func checkCallbackResponse(resp *immune.CallbackResponse) []error {
+	if resp == nil {
+		return nil
+	}
+
+	var problems []error
+	steps := append([]immune.CallbackResponseStep{{StatusCode: resp.StatusCode}}, resp.Script...)
+	for _, step := range steps {
+		if step.StatusCode != 0 && (step.StatusCode < 100 || step.StatusCode > 599) {
+			problems = append(problems, fmt.Errorf("valid range for status_code is 100-599, got %d", step.StatusCode))
+		}
+	}
+
+	return problems
+}
+
+// applyRetryDefaults defaults the strategy of the retry expectation of cb to constant, and the
+// number of callbacks to wait for to the expected number of attempts. As with times, attempts
+// arriving after the expected ones within the quiet window fail the test case
+func applyRetryDefaults(cb *immune.Callback) {
+	re := cb.Retry
+	if re == nil {
+		return
+	}
+
+	if re.Strategy == "" {
+		re.Strategy = immune.RetryStrategyConstant
+	}
+
+	// retry can't be used when no callback is expected, see checkExpectNone
+	if cb.Times == 0 && !cb.ExpectsNone() {
+		cb.Times = retryCallbacks(cb)
+	}
+}
+
+// retryCallbacks returns the number of callbacks the retry expectation of cb amounts
+// to, the attempts are made at each of the receivers of cb
+func retryCallbacks(cb *immune.Callback) uint {
+	receivers := uint(len(cb.Receivers))
+	if receivers == 0 {
+		receivers = 1
+	}
+	return cb.Retry.Attempts * receivers
+}
+
+// checkRetryExpectation validates the retry expectation of cb
+func checkRetryExpectation(cb *immune.Callback) []error {
+	re := cb.Retry
+	if re == nil {
+		return nil
+	}
+
+	var problems []error
+
+	if re.Attempts == 0 {
+		problems = append(problems, errors.New("attempts must be greater than 0"))
+	}
+
+	switch re.Strategy {
+	case immune.RetryStrategyConstant, immune.RetryStrategyExponential:
+	default:
+		problems = append(problems, fmt.Errorf("unknown strategy %s", re.Strategy))
+	}
+
+	if re.IntervalSeconds > 0 && re.IntervalMS > 0 {
+		problems = append(problems, errors.New("interval_seconds and interval_ms cannot both be set"))
+	} else if re.Attempts > 1 && re.IntervalSeconds == 0 && re.IntervalMS == 0 {
+		problems = append(problems, errors.New("interval_seconds or interval_ms must be greater than 0"))
+	}
+
+	if !cb.ExpectsNone() && cb.Times != retryCallbacks(cb) {
+		if len(cb.Receivers) > 1 {
+			problems = append(problems, fmt.Errorf("times %d does not match attempts %d at each of %d receivers", cb.Times, re.Attempts, len(cb.Receivers)))
+		} else {
+			problems = append(problems, fmt.Errorf("times %d does not match attempts %d", cb.Times, re.Attempts))
+		}
+	}
+
+	return problems
+}
+
+// checkExpectNone validates the negative expectation of cb
+func checkExpectNone(cb *immune.Callback) []error {
+	switch cb.Expect {
+	case "", immune.CallbackExpectNone:
+	default:
+		return []error{fmt.Errorf("unknown expect %s", cb.Expect)}
+	}
+
+	if !cb.ExpectsNone() {
+		return nil
+	}
+
+	var problems []error
 
-			if tc.Callback.Times == 0 {
-				return fmt.Errorf("test_case %s: if callback is enabled then times must be greater than 0", tc.Name)
+	if cb.Times > 0 {
+		problems = append(problems, errors.New("times must be 0 when no callback is expected"))
+	}
+
+	if cb.Retry != nil {
+		problems = append(problems, errors.New("retry cannot be used when no callback is expected"))
+	}
+
+	return problems
+}
+
+// checkCallbackCount validates the exact, min & max callback counts of cb
+func checkCallbackCount(cb *immune.Callback) []error {
+	if cb.ExpectsNone() {
+		return nil
+	}
+
+	if cb.Times == 0 && cb.Min == 0 && cb.Max == 0 {
+		return []error{errors.New("if callback is enabled then times, min or max must be greater than 0")}
+	}
+
+	var problems []error
+
+	if cb.Times > 0 && (cb.Min > 0 || cb.Max > 0) {
+		problems = append(problems, errors.New("callback times cannot be used with min or max"))
+	}
+
+	if cb.Max > 0 && cb.Min > cb.Max {
+		problems = append(problems, fmt.Errorf("callback min %d cannot be greater than max %d", cb.Min, cb.Max))
+	}
+
+	return problems
+}
+
+func (s *System) hasReceiver(name string) bool {
+	for _, r := range s.Callback.AllReceivers() {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReceivers validates the callback receivers, receivers sharing a port
+// must share its ssl configuration, and no two receivers can have the same port & route
+func checkReceivers(cfg *immune.CallbackConfiguration) []error {
+	var problems []error
+	names := map[string]bool{}
+	routes := map[string]bool{}
+	ports := map[uint]immune.CallbackReceiver{}
+
+	for i, r := range cfg.AllReceivers() {
+		if i > 0 {
+			if r.Name == "" {
+				problems = append(problems, errors.New("callback receiver name cannot be empty"))
 			}
+
+			if r.Name == immune.DefaultReceiverName {
+				problems = append(problems, fmt.Errorf("callback receiver name %s is reserved", immune.DefaultReceiverName))
+			}
+
+			if !strings.HasPrefix(r.Route, "/") {
+				problems = append(problems, fmt.Errorf("callback receiver %s: route must begin with /", r.Name))
+			}
+
+			if r.SSL && !cfg.AutoTLS && (r.SSLCertFile == "" || r.SSLKeyFile == "") {
+				problems = append(problems, fmt.Errorf("callback receiver %s: both cert_file and key_file are required for ssl", r.Name))
+			}
+
+			problems = append(problems, prefixed(fmt.Sprintf("callback receiver %s: response", r.Name), checkCallbackResponse(r.Response))...)
+		}
+
+		if names[r.Name] {
+			problems = append(problems, fmt.Errorf("duplicate callback receiver %s", r.Name))
+		}
+		names[r.Name] = true
+
+		for _, reserved := range []string{callback.IntrospectionRoute, callback.LinkRoute} {
+			if strings.HasPrefix(r.Route, reserved) {
+				problems = append(problems, fmt.Errorf("callback receiver %s: route cannot be under %s", r.Name, reserved))
+			}
+		}
+
+		key := fmt.Sprintf("%d%s", r.Port, r.Route)
+		if routes[key] {
+			problems = append(problems, fmt.Errorf("callback receiver %s: route %s is already used on port %d", r.Name, r.Route, r.Port))
+		}
+		routes[key] = true
+
+		other, ok := ports[r.Port]
+		if !ok {
+			ports[r.Port] = r
+			continue
+		}
+
+		if other.SSL != r.SSL || other.SSLCertFile != r.SSLCertFile || other.SSLKeyFile != r.SSLKeyFile {
+			problems = append(problems, fmt.Errorf("callback receiver %s: ssl configuration differs from receiver %s on port %d", r.Name, other.Name, r.Port))
 		}
 	}
 
-	return nil
+	return problems
 }
 
-func (s *System) NeedsCallbackServer() bool {
-	return s.needsCallback
+// checkTLS validates the generated certificate & client verification settings
+func checkTLS(cfg *immune.CallbackConfiguration) []error {
+	ssl := false
+	for _, r := range cfg.AllReceivers() {
+		ssl = ssl || r.SSL
+	}
+
+	var problems []error
+
+	if (cfg.AutoTLS || cfg.VerifyClientCert) && !ssl {
+		problems = append(problems, errors.New("auto_tls and verify_client_cert require ssl to be enabled on at least one callback receiver"))
+	}
+
+	if !cfg.AutoTLS && (cfg.CACertOutFile != "" || cfg.ClientCertOutFile != "" || cfg.ClientKeyOutFile != "") {
+		problems = append(problems, errors.New("ca_cert_out_file, client_cert_out_file and client_key_out_file require auto_tls"))
+	}
+
+	if (cfg.ClientCertOutFile == "") != (cfg.ClientKeyOutFile == "") {
+		problems = append(problems, errors.New("both client_cert_out_file and client_key_out_file are required for a generated client certificate"))
+	}
+
+	if cfg.VerifyClientCert && !cfg.AutoTLS && cfg.ClientCAFile == "" {
+		problems = append(problems, errors.New("verify_client_cert requires client_ca_file or auto_tls"))
+	}
+
+	return problems
+}
+
+// checkCallbackIDLocations validates where callback ids are injected into
+// requests, and where each receiver reads them from
+func checkCallbackIDLocations(cfg *immune.CallbackConfiguration) []error {
+	var problems []error
+
+	if cfg.IDLocation != "" {
+		_, err := immune.ParseCallbackIDLocation(cfg.IDLocation)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("callback id_location: %v", err))
+		}
+	}
+
+	for _, r := range cfg.AllReceivers() {
+		if r.IDSource == "" {
+			continue
+		}
+
+		loc, err := immune.ParseCallbackIDLocation(r.IDSource)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("callback receiver %s: id_source: %v", r.Name, err))
+			continue
+		}
+
+		if loc.Source == immune.CallbackIDSourceQuery {
+			problems = append(problems, fmt.Errorf("callback receiver %s: id_source must be a body or header location", r.Name))
+		}
+	}
+
+	return problems
 }
@@ -0,0 +1,86 @@
+package system
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Validate lints the whole system without sending any request, it applies the defaults
+// like Clean does, but returns every problem found rather than stopping at the first one
+func (s *System) Validate() []error {
+	s.applyDefaults()
+	problems := s.check()
+
+	// an empty or unparsable base url is a problem found by check
+	u, err := url.Parse(s.BaseURL)
+	if s.BaseURL != "" && err == nil && (u.Scheme == "" || u.Host == "") {
+		problems = append(problems, errors.Errorf("base_url %s must be an absolute url", s.BaseURL))
+	}
+
+	if s.EventTargetURL != "" {
+		u, err := url.Parse(s.EventTargetURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, errors.Errorf("event_target_url %s must be an absolute url", s.EventTargetURL))
+		}
+	}
+
+	if len(s.SetupTestCases) > 0 {
+		log.Warn("setup_test_cases are not executed, use the setup of the test cases instead")
+	}
+
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+
+		for _, setupName := range tc.Setup {
+			setupTC, err := s.setupTestCase(setupName)
+			if err != nil {
+				problems = append(problems, errors.Wrapf(err, "test_case %s", tc.Name))
+				continue
+			}
+
+			if setupTC.Name == "setup_endpoint" && !s.hasEventTargetURL(setupName) {
+				problems = append(problems, errors.Errorf("test_case %s: %s needs an event_target_url or callback public_host", tc.Name, setupName))
+			}
+		}
+
+		if tc.BodyFile != "" {
+			if _, err := os.Stat(tc.BodyFile); err != nil {
+				problems = append(problems, errors.Errorf("test_case %s: body_file %s does not exist", tc.Name, tc.BodyFile))
+			}
+		}
+	}
+
+	// the analysis needs a valid system, every setup to resolve & body file to exist
+	if len(problems) > 0 {
+		return problems
+	}
+
+	issues, err := s.AnalyseVariables()
+	if err != nil {
+		return append(problems, err)
+	}
+
+	for _, issue := range issues {
+		if issue.IsError() {
+			problems = append(problems, issue)
+			continue
+		}
+		log.Warn(issue)
+	}
+
+	return problems
+}
+
+// hasEventTargetURL reports whether the setup_endpoint named setupName will have an event target
+// url, either configured or derived from the public host of the callback server when it starts
+func (s *System) hasEventTargetURL(setupName string) bool {
+	target := s.EventTargetURL
+	if setupName != "setup_endpoint" {
+		target, _ = s.receiverTargetURL(setupName)
+	}
+
+	return target != "" || (s.Callback.PublicHost != "" && s.Callback.RemoteURL == "")
+}
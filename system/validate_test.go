@@ -0,0 +1,257 @@
+package system
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystem_Validate(t *testing.T) {
+	validTestCase := func() immune.TestCase {
+		return immune.TestCase{
+			Name:        "create_app",
+			Setup:       []string{"setup_group"},
+			StatusCode:  201,
+			HTTPMethod:  "POST",
+			Endpoint:    "/applications?groupId={group_id}",
+			RequestBody: immune.M{"name": "app"},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		sys          *System
+		wantProblems []string
+	}{
+		{
+			name: "should_pass_for_valid_system",
+			sys: &System{
+				BaseURL:   "http://localhost:5005/api/v1",
+				TestCases: []immune.TestCase{validTestCase()},
+			},
+		},
+		{
+			name: "should_return_every_problem",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", StatusCode: 0, HTTPMethod: "POST", Endpoint: "events"},
+					{Name: "b", StatusCode: 201, HTTPMethod: "FETCH", Endpoint: "/events", Callback: immune.Callback{Enabled: true, Min: 3, Max: 2}},
+				},
+			},
+			wantProblems: []string{
+				"base url cannot be empty",
+				"test_case a: endpoint must begin with /",
+				"test_case a: valid range for status_code is 100-511",
+				"test_case b: invalid method: FETCH",
+				"test_case b: callback min 3 cannot be greater than max 2",
+			},
+		},
+		{
+			name: "should_return_every_callback_problem",
+			sys: &System{
+				BaseURL: "http://localhost:5005/api/v1",
+				TestCases: []immune.TestCase{
+					{
+						Name:       "a",
+						StatusCode: 201,
+						HTTPMethod: "POST",
+						Endpoint:   "/events",
+						Callback: immune.Callback{
+							Enabled:   true,
+							Times:     2,
+							Ordered:   true,
+							Receivers: []string{"billing"},
+							Response:  &immune.CallbackResponse{StatusCode: 700},
+						},
+					},
+				},
+			},
+			wantProblems: []string{
+				"test_case a: callback ordered requires repeat to be greater than 1",
+				"test_case a: unknown callback receiver billing",
+				"test_case a: callback response: valid range for status_code is 100-599, got 700",
+			},
+		},
+		{
+			name: "should_error_for_relative_urls",
+			sys: &System{
+				BaseURL:        "localhost:5005",
+				EventTargetURL: "/webhooks",
+				TestCases:      []immune.TestCase{validTestCase()},
+			},
+			wantProblems: []string{
+				"base_url localhost:5005 must be an absolute url",
+				"event_target_url /webhooks must be an absolute url",
+			},
+		},
+		{
+			name: "should_error_for_unknown_setup_and_missing_body_file",
+			sys: &System{
+				BaseURL: "http://localhost:5005/api/v1",
+				TestCases: []immune.TestCase{
+					{
+						Name:       "a",
+						Setup:      []string{"setup_group", "setup_tenant", "setup_endpoint"},
+						StatusCode: 201,
+						HTTPMethod: "POST",
+						Endpoint:   "/events",
+						BodyFile:   filepath.Join("testdata", "missing.json"),
+					},
+				},
+			},
+			wantProblems: []string{
+				"test_case a: unknown setup setup_tenant",
+				"test_case a: setup_endpoint needs an event_target_url or callback public_host",
+				"test_case a: body_file " + filepath.Join("testdata", "missing.json") + " does not exist",
+			},
+		},
+		{
+			name: "should_error_for_undefined_variable",
+			sys: &System{
+				BaseURL: "http://localhost:5005/api/v1",
+				TestCases: []immune.TestCase{
+					{Name: "a", StatusCode: 200, HTTPMethod: "GET", Endpoint: "/applications/{app_id}"},
+				},
+			},
+			wantProblems: []string{
+				"test_cases[0].endpoint: variable app_id is never defined, it must be stored by a setup or store_response_headers",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, problem := range tt.sys.Validate() {
+				got = append(got, problem.Error())
+			}
+
+			require.Equal(t, tt.wantProblems, got)
+		})
+	}
+}
+
+func TestSystem_Clean(t *testing.T) {
+	sys := &System{
+		BaseURL: "http://localhost:5005/api/v1",
+		TestCases: []immune.TestCase{
+			{
+				Name:       "a",
+				StatusCode: 201,
+				HTTPMethod: "POST",
+				Endpoint:   "/events",
+				Callback:   immune.Callback{Enabled: true, Retry: &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1}},
+			},
+			{Name: "b", StatusCode: 201, HTTPMethod: "FETCH", Endpoint: "events"},
+		},
+	}
+
+	// only the first problem is returned
+	err := sys.Clean()
+	require.Error(t, err)
+	require.Equal(t, "test_case b: endpoint must begin with /", err.Error())
+
+	// the defaults are applied
+	require.Equal(t, uint(maxCallbackWait), sys.Callback.MaxWaitSeconds)
+	require.Equal(t, immune.BodyTypeJSON, sys.TestCases[0].BodyType)
+	require.Equal(t, immune.RetryStrategyConstant, sys.TestCases[0].Callback.Retry.Strategy)
+	require.Equal(t, uint(3), sys.TestCases[0].Callback.Times)
+}
+
+func TestSystem_Validate_Retry(t *testing.T) {
+	retryTestCase := func(cb immune.Callback) immune.TestCase {
+		cb.Enabled = true
+		return immune.TestCase{Name: "a", StatusCode: 201, HTTPMethod: "POST", Endpoint: "/events", Callback: cb}
+	}
+
+	tests := []struct {
+		name         string
+		tc           immune.TestCase
+		wantTimes    uint
+		wantProblems []string
+	}{
+		{
+			name:      "should_default_times_to_attempts",
+			tc:        retryTestCase(immune.Callback{Retry: &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1}}),
+			wantTimes: 3,
+		},
+		{
+			name: "should_default_times_to_attempts_at_each_receiver",
+			tc: retryTestCase(immune.Callback{
+				Receivers: []string{"billing", "orders"},
+				Retry:     &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1},
+			}),
+			wantTimes: 6,
+		},
+		{
+			name: "should_error_for_times_not_matching_attempts_at_each_receiver",
+			tc: retryTestCase(immune.Callback{
+				Times:     3,
+				Receivers: []string{"billing", "orders"},
+				Retry:     &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1},
+			}),
+			wantTimes: 3,
+			wantProblems: []string{
+				"test_case a: callback retry: times 3 does not match attempts 3 at each of 2 receivers",
+			},
+		},
+		{
+			name: "should_default_times_to_attempts_with_quiet_window",
+			tc: retryTestCase(immune.Callback{
+				QuietWindowSeconds: 5,
+				Retry:              &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1},
+			}),
+			wantTimes: 3,
+		},
+		{
+			name:      "should_accept_sub_second_interval",
+			tc:        retryTestCase(immune.Callback{Retry: &immune.RetryExpectation{Attempts: 3, IntervalMS: 200}}),
+			wantTimes: 3,
+		},
+		{
+			name:      "should_error_for_missing_interval",
+			tc:        retryTestCase(immune.Callback{Retry: &immune.RetryExpectation{Attempts: 3}}),
+			wantTimes: 3,
+			wantProblems: []string{
+				"test_case a: callback retry: interval_seconds or interval_ms must be greater than 0",
+			},
+		},
+		{
+			name:      "should_error_for_both_intervals",
+			tc:        retryTestCase(immune.Callback{Retry: &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1, IntervalMS: 200}}),
+			wantTimes: 3,
+			wantProblems: []string{
+				"test_case a: callback retry: interval_seconds and interval_ms cannot both be set",
+			},
+		},
+		{
+			name: "should_error_for_retry_with_expect_none",
+			tc: retryTestCase(immune.Callback{
+				Expect:             immune.CallbackExpectNone,
+				QuietWindowSeconds: 5,
+				Retry:              &immune.RetryExpectation{Attempts: 3, IntervalSeconds: 1},
+			}),
+			wantProblems: []string{
+				"test_case a: callback: retry cannot be used when no callback is expected",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := &System{
+				BaseURL:   "http://localhost:5005/api/v1",
+				Callback:  immune.CallbackConfiguration{Receivers: []immune.CallbackReceiver{{Name: "billing", Route: "/billing"}, {Name: "orders", Route: "/orders"}}},
+				TestCases: []immune.TestCase{tt.tc},
+			}
+
+			var got []string
+			for _, problem := range sys.Validate() {
+				got = append(got, problem.Error())
+			}
+
+			require.Equal(t, tt.wantProblems, got)
+			require.Equal(t, tt.wantTimes, sys.TestCases[0].Callback.Times)
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/frain-dev/immune"
+	"github.com/pkg/errors"
+)
+
+// VariableIssueKind is the kind of problem found by the variable flow analysis
+type VariableIssueKind string
+
+const (
+	// VariableUndefined is a variable used before any step stores it, the request would fail
+	VariableUndefined VariableIssueKind = "undefined"
+	// VariableUnused is a variable stored by a step but never used by a later step
+	VariableUnused VariableIssueKind = "unused"
+	// VariableCollision is a variable stored by two different setups, one overwrites the other
+	VariableCollision VariableIssueKind = "collision"
+)
+
+// VariableIssue is a problem found by the variable flow analysis, Location points
+// at the config field the variable is used or stored in, e.g test_cases[1].setup[0]
+type VariableIssue struct {
+	Kind     VariableIssueKind
+	Variable string
+	Location string
+	// Detail explains the issue, e.g where the variable is defined
+	Detail string
+}
+
+func (vi VariableIssue) Error() string {
+	return fmt.Sprintf("%s: variable %s %s", vi.Location, vi.Variable, vi.Detail)
+}
+
+// IsError reports whether the issue makes the run fail, unused variables & collisions are only warnings
+func (vi VariableIssue) IsError() bool {
+	return vi.Kind == VariableUndefined
+}
+
+// variableRef is a variable used or stored at a location of the config
+type variableRef struct {
+	name     string
+	location string
+}
+
+// definition is where a variable was last stored, and by which step
+type definition struct {
+	variableRef
+	used bool
+}
+
+// AnalyseVariables follows the variables through the setups and test cases in execution order
+// without sending any request. Variables persist across test cases, as the variable map does.
+// It expects the system to have been cleaned, see Clean
+func (s *System) AnalyseVariables() ([]VariableIssue, error) {
+	var issues []VariableIssue
+
+	defined := map[string]*definition{}
+	// firstDefined & used are kept per variable to report unused variables once
+	firstDefined := map[string]string{}
+	used := map[string]bool{}
+	// overwritten are the values stored but overwritten before any use, they are only
+	// reported for variables used elsewhere, since setups always store every variable
+	var overwritten []VariableIssue
+	// producers records the first location each variable is stored at by each step,
+	// to report collisions once per pair of steps
+	producers := map[string]map[string]string{}
+
+	consume := func(refs []variableRef) {
+		for _, ref := range refs {
+			def, ok := defined[ref.name]
+			if !ok {
+				issues = append(issues, VariableIssue{
+					Kind:     VariableUndefined,
+					Variable: ref.name,
+					Location: ref.location,
+				})
+				continue
+			}
+			def.used = true
+			used[ref.name] = true
+		}
+	}
+
+	produce := func(producer string, refs []variableRef) {
+		for _, ref := range refs {
+			if def, ok := defined[ref.name]; ok && !def.used {
+				overwritten = append(overwritten, VariableIssue{
+					Kind:     VariableUnused,
+					Variable: ref.name,
+					Location: def.location,
+					Detail:   fmt.Sprintf("is overwritten at %s before it is used", ref.location),
+				})
+			}
+			defined[ref.name] = &definition{variableRef: ref}
+			if _, ok := firstDefined[ref.name]; !ok {
+				firstDefined[ref.name] = ref.location
+			}
+
+			if producers[ref.name] == nil {
+				producers[ref.name] = map[string]string{}
+			}
+			if _, ok := producers[ref.name][producer]; ok {
+				continue
+			}
+			for _, other := range sortedKeys(immune.S(producers[ref.name])) {
+				issues = append(issues, VariableIssue{
+					Kind:     VariableCollision,
+					Variable: ref.name,
+					Location: ref.location,
+					Detail:   fmt.Sprintf("stored by %s is also stored by %s at %s", producer, other, producers[ref.name][other]),
+				})
+			}
+			producers[ref.name][producer] = ref.location
+		}
+	}
+
+	for i := range s.TestCases {
+		tc := &s.TestCases[i]
+		if tc.Skip { // skipped test cases neither define nor use variables
+			continue
+		}
+		prefix := fmt.Sprintf("test_cases[%d]", i)
+
+		for j, setupName := range tc.Setup {
+			setupTC, err := s.setupTestCase(setupName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s.setup[%d]", prefix, j)
+			}
+
+			location := fmt.Sprintf("%s.setup[%d] (%s)", prefix, j, setupName)
+			consume(setupRefs(setupTC, location))
+			produce(setupTC.Name, storedRefs(setupTC.StoreResponseVariables, location, false))
+		}
+
+		refs, err := requestRefs(tc, prefix, s.rawBodyHasCallbackID(tc))
+		if err != nil {
+			return nil, err
+		}
+		consume(refs)
+		produce("test_case "+tc.Name, storedRefs(tc.StoreResponseHeaders, prefix+".store_response_headers", true))
+	}
+
+	for i := range issues {
+		if issues[i].Kind != VariableUndefined {
+			continue
+		}
+
+		if location, ok := firstDefined[issues[i].Variable]; ok {
+			issues[i].Detail = "is used before it is defined at " + location
+		} else {
+			issues[i].Detail = "is never defined, it must be stored by a setup or store_response_headers"
+		}
+	}
+
+	for _, issue := range overwritten {
+		if used[issue.Variable] {
+			issues = append(issues, issue)
+		}
+	}
+
+	for _, name := range sortedKeys(immune.S(firstDefined)) {
+		if !used[name] {
+			issues = append(issues, VariableIssue{
+				Kind:     VariableUnused,
+				Variable: name,
+				Location: firstDefined[name],
+				Detail:   "is never used",
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].IsError() && !issues[j].IsError()
+	})
+	return issues, nil
+}
+
+// setupRefs returns the variables used by a setup, they all point at the setup since
+// its endpoint & body come from the setup functions rather than from the config
+func setupRefs(setupTC *immune.SetupTestCase, location string) []variableRef {
+	names := union(immune.VariableRefs(setupTC.Endpoint), setupTC.RequestBody.VariableRefs())
+
+	refs := make([]variableRef, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, variableRef{name: name, location: location})
+	}
+	return refs
+}
+
+// requestRefs returns the variables used by the request of tc, in the order they are replaced.
+// With callbackID, the callback id is defined for the raw body and its references are left out
+func requestRefs(tc *immune.TestCase, prefix string, callbackID bool) ([]variableRef, error) {
+	var refs []variableRef
+	for _, name := range immune.VariableRefs(tc.Endpoint) {
+		refs = append(refs, variableRef{name: name, location: prefix + ".endpoint"})
+	}
+
+	refs = append(refs, bodyRefs(map[string]interface{}(tc.RequestBody), prefix+".request_body")...)
+
+	raw := func(names []string, location string) {
+		for _, name := range names {
+			if callbackID && name == immune.CallbackIDFieldName {
+				continue
+			}
+			refs = append(refs, variableRef{name: name, location: location})
+		}
+	}
+
+	raw(immune.VariableRefs(tc.RawBody), prefix+".raw_body")
+
+	if tc.BodyFile != "" {
+		b, err := ioutil.ReadFile(tc.BodyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s.body_file: failed to read body file", prefix)
+		}
+
+		raw(immune.VariableRefs(string(b)), fmt.Sprintf("%s.body_file (%s)", prefix, tc.BodyFile))
+	}
+
+	return refs, nil
+}
+
+// rawBodyHasCallbackID reports whether the raw body of tc can reference the callback id as
+// the immune_callback_id variable. Raw bodies can't be injected into, so the executor defines
+// it for the request of a callback test case whose id goes in the body
+func (s *System) rawBodyHasCallbackID(tc *immune.TestCase) bool {
+	hasRawBody := tc.BodyType == immune.BodyTypeRaw || tc.BodyType == immune.BodyTypeXML || tc.BodyFile != ""
+	if !tc.Callback.Enabled || !hasRawBody {
+		return false
+	}
+
+	location := tc.Callback.IDLocation
+	if location == "" {
+		location = s.Callback.IDLocation
+	}
+
+	loc, err := immune.ParseCallbackIDLocation(location)
+	return err == nil && loc.Source == immune.CallbackIDSourceBody
+}
+
+// bodyRefs returns the variables used by the values of a request body, only values made up
+// entirely of a reference are replaced, see immune.M.VariableRefs
+func bodyRefs(v interface{}, location string) []variableRef {
+	var refs []variableRef
+	switch value := v.(type) {
+	case string:
+		if len(value) > 2 && value[0] == '{' && value[len(value)-1] == '}' {
+			refs = append(refs, variableRef{name: value[1 : len(value)-1], location: location})
+		}
+	case immune.M:
+		refs = bodyRefs(map[string]interface{}(value), location)
+	case map[string]interface{}:
+		for _, key := range sortedBodyKeys(value) {
+			refs = append(refs, bodyRefs(value[key], location+"."+key)...)
+		}
+	case []interface{}:
+		for i, item := range value {
+			refs = append(refs, bodyRefs(item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+	}
+	return refs
+}
+
+// storedRefs returns the variables stored by vars, sorted by name. With perField, the
+// location points at the field of each variable, setups have no fields in the config
+func storedRefs(vars immune.S, location string, perField bool) []variableRef {
+	refs := make([]variableRef, 0, len(vars))
+	for _, name := range sortedKeys(vars) {
+		ref := variableRef{name: name, location: location}
+		if perField {
+			ref.location += "." + name
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func sortedBodyKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,168 @@
+package system
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/frain-dev/immune"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystem_AnalyseVariables(t *testing.T) {
+	tests := []struct {
+		name       string
+		sys        *System
+		wantIssues []string
+	}{
+		{
+			name: "should_pass_for_defined_variables",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Setup: []string{"setup_group", "setup_app"}, Endpoint: "/applications/{app_id}?groupId={group_id}"},
+				},
+			},
+		},
+		{
+			name: "should_error_for_undefined_variables",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Endpoint: "/applications/{app_id}", RequestBody: immune.M{"data": immune.M{"group": "{group_id}"}}},
+					{Name: "b", Setup: []string{"setup_group", "setup_app"}, Endpoint: "/applications/{app_id}?groupId={group_id}"},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[0].endpoint: variable app_id is used before it is defined at test_cases[1].setup[1] (setup_app)",
+				"test_cases[0].request_body.data.group: variable group_id is used before it is defined at test_cases[1].setup[0] (setup_group)",
+			},
+		},
+		{
+			name: "should_error_for_variables_never_defined",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Endpoint: "/events/{event_id}"},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[0].endpoint: variable event_id is never defined, it must be stored by a setup or store_response_headers",
+			},
+		},
+		{
+			name: "should_warn_for_unused_variables",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Setup: []string{"setup_group"}, Endpoint: "/groups", StoreResponseHeaders: immune.S{"location": "Location"}},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[0].setup[0] (setup_group): variable group_id is never used",
+				"test_cases[0].store_response_headers.location: variable location is never used",
+			},
+		},
+		{
+			name: "should_warn_for_overwritten_variables",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Endpoint: "/groups", StoreResponseHeaders: immune.S{"location": "Location"}},
+					{Name: "b", Endpoint: "/groups", StoreResponseHeaders: immune.S{"location": "Location"}},
+					{Name: "c", Endpoint: "{location}"},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[1].store_response_headers.location: variable location stored by test_case b is also stored by test_case a at test_cases[0].store_response_headers.location",
+				"test_cases[0].store_response_headers.location: variable location is overwritten at test_cases[1].store_response_headers.location before it is used",
+			},
+		},
+		{
+			name: "should_warn_for_collisions",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Setup: []string{"setup_group"}, Endpoint: "/groups/{group_id}", StoreResponseHeaders: immune.S{"group_id": "X-Group-ID"}},
+					{Name: "b", Endpoint: "/groups/{group_id}"},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[0].store_response_headers.group_id: variable group_id stored by test_case a is also stored by setup_group at test_cases[0].setup[0] (setup_group)",
+			},
+		},
+		{
+			name: "should_define_callback_id_for_raw_body",
+			sys: &System{
+				Callback: immune.CallbackConfiguration{IDLocation: "immune_callback_id"},
+				TestCases: []immune.TestCase{
+					{
+						Name:     "a",
+						Setup:    []string{"setup_group", "setup_app"},
+						Endpoint: "/events?groupId={group_id}",
+						BodyType: immune.BodyTypeXML,
+						BodyFile: filepath.Join("testdata", "event.xml"),
+						Callback: immune.Callback{Enabled: true, Times: 1},
+					},
+					{
+						Name:     "b",
+						Endpoint: "/events?groupId={group_id}",
+						BodyType: immune.BodyTypeRaw,
+						RawBody:  "{immune_callback_id}",
+						Callback: immune.Callback{Enabled: true, Times: 1},
+					},
+				},
+			},
+		},
+		{
+			name: "should_error_for_callback_id_outside_raw_body",
+			sys: &System{
+				Callback: immune.CallbackConfiguration{IDLocation: "header:X-Immune-Callback-ID"},
+				TestCases: []immune.TestCase{
+					{
+						Name:     "a",
+						Endpoint: "/events/{immune_callback_id}",
+						BodyType: immune.BodyTypeRaw,
+						RawBody:  "{immune_callback_id}",
+						Callback: immune.Callback{Enabled: true, Times: 1},
+					},
+					{
+						Name:     "b",
+						Endpoint: "/events",
+						BodyType: immune.BodyTypeRaw,
+						RawBody:  "{immune_callback_id}",
+					},
+				},
+			},
+			wantIssues: []string{
+				"test_cases[0].endpoint: variable immune_callback_id is never defined, it must be stored by a setup or store_response_headers",
+				"test_cases[0].raw_body: variable immune_callback_id is never defined, it must be stored by a setup or store_response_headers",
+				"test_cases[1].raw_body: variable immune_callback_id is never defined, it must be stored by a setup or store_response_headers",
+			},
+		},
+		{
+			name: "should_ignore_skipped_test_cases",
+			sys: &System{
+				TestCases: []immune.TestCase{
+					{Name: "a", Endpoint: "/applications/{app_id}", Skip: true},
+					{Name: "b", Setup: []string{"setup_group"}, Endpoint: "/groups/{group_id}"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := tt.sys.AnalyseVariables()
+			require.NoError(t, err)
+
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.Error())
+			}
+			require.Equal(t, tt.wantIssues, got)
+		})
+	}
+}
+
+func TestSystem_AnalyseVariables_UnknownSetup(t *testing.T) {
+	sys := &System{
+		TestCases: []immune.TestCase{{Name: "a", Setup: []string{"setup_tenant"}, Endpoint: "/tenants"}},
+	}
+
+	_, err := sys.AnalyseVariables()
+	require.Error(t, err)
+	require.Equal(t, "test_cases[0].setup[0]: unknown setup setup_tenant", err.Error())
+}
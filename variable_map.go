@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 )
@@ -170,3 +171,18 @@ func getArrayValue(v string, m M) (interface{}, error) {
 
 	return sliceValue[ix], nil
 }
+
+// ProcessHeaders takes the headers declared in variableToHeader from header, and stores them in the
+// variable map.
+func (v *VariableMap) ProcessHeaders(variableToHeader S, header http.Header) error {
+	for varName, name := range variableToHeader {
+		values, ok := header[http.CanonicalHeaderKey(name)]
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("header %s: not found", name)
+		}
+
+		v.VariableToValue[varName] = values[0]
+	}
+
+	return nil
+}
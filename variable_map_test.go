@@ -2,6 +2,7 @@ package immune
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -113,6 +114,22 @@ func TestVariableMap_ProcessResponse(t *testing.T) {
 	}
 }
 
+func TestVariableMap_ProcessHeaders(t *testing.T) {
+	header := http.Header{
+		"Location":     []string{"/users/1234"},
+		"X-Request-Id": []string{"abc-123"},
+	}
+
+	v := NewVariableMap()
+	err := v.ProcessHeaders(S{"user_location": "location", "request_id": "X-Request-Id"}, header)
+	require.NoError(t, err)
+	require.Equal(t, M{"user_location": "/users/1234", "request_id": "abc-123"}, v.VariableToValue)
+
+	err = v.ProcessHeaders(S{"etag": "ETag"}, header)
+	require.Error(t, err)
+	require.Equal(t, "header ETag: not found", err.Error())
+}
+
 func TestVariableMap_GetString(t *testing.T) {
 	type fields struct {
 		VariableToValue M
@@ -0,0 +1,80 @@
+package immune
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// variableRefRegex matches a variable reference in the format "{variable_name}"
+var variableRefRegex = regexp.MustCompile(`\{([A-Za-z0-9_.\[\]-]+)\}`)
+
+// VariableRefs returns the names of the variables referenced anywhere in s,
+// as in urls and raw bodies, in order of first reference
+func VariableRefs(s string) []string {
+	var refs []string
+	seen := map[string]bool{}
+	for _, match := range variableRefRegex.FindAllStringSubmatch(s, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			refs = append(refs, match[1])
+		}
+	}
+	return refs
+}
+
+// ReplaceVariableRefs replaces every variable reference in s with the value lookup returns for
+// its name, as in raw bodies. It errors on the first variable lookup does not know
+func ReplaceVariableRefs(s string, lookup func(name string) (string, bool)) (string, error) {
+	var err error
+	result := variableRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[1 : len(ref)-1]
+		value, ok := lookup(name)
+		if !ok && err == nil {
+			err = errors.Errorf("variable %s does not exist in variable map", name)
+		}
+		return value
+	})
+
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// VariableRefs returns the names of the variables referenced by the values of m, only string
+// values made up entirely of a reference e.g "{app_id}" are replaced, so only those count
+func (m M) VariableRefs() []string {
+	set := map[string]bool{}
+	collectBodyRefs(map[string]interface{}(m), set)
+
+	refs := make([]string, 0, len(set))
+	for name := range set {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func collectBodyRefs(v interface{}, set map[string]bool) {
+	switch value := v.(type) {
+	case string:
+		if len(value) > 2 && strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+			set[value[1:len(value)-1]] = true
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectBodyRefs(item, set)
+		}
+	case M:
+		for _, item := range value {
+			collectBodyRefs(item, set)
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectBodyRefs(item, set)
+		}
+	}
+}
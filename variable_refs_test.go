@@ -0,0 +1,93 @@
+package immune
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariableRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{
+			name: "should_return_refs_in_order_of_first_reference",
+			s:    "/applications/{app_id}/endpoints?groupId={group_id}&app={app_id}",
+			want: []string{"app_id", "group_id"},
+		},
+		{
+			name: "should_return_refs_within_raw_body",
+			s:    `<event id="{event_id}">{data.uid}</event>`,
+			want: []string{"event_id", "data.uid"},
+		},
+		{
+			name: "should_return_no_refs",
+			s:    "/groups",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, VariableRefs(tt.s))
+		})
+	}
+}
+
+func TestM_VariableRefs(t *testing.T) {
+	m := M{
+		"app_id": "{app_id}",
+		"name":   "app {app_name}", // only whole values are replaced
+		"data": map[string]interface{}{
+			"group_id": "{group_id}",
+			"ids":      []interface{}{"{event_id}", 1, "{app_id}"},
+		},
+		"empty": "{}",
+	}
+
+	require.Equal(t, []string{"app_id", "event_id", "group_id"}, m.VariableRefs())
+}
+
+func TestReplaceVariableRefs(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		value, ok := map[string]string{"app_id": "123", "data.id": "456"}[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name       string
+		s          string
+		want       string
+		wantErrMsg string
+	}{
+		{
+			name: "should_replace_every_reference",
+			s:    `<event app="{app_id}" id="{data.id}">{app_id}</event>`,
+			want: `<event app="123" id="456">123</event>`,
+		},
+		{
+			name: "should_leave_non_references",
+			s:    `{"raw": true}`,
+			want: `{"raw": true}`,
+		},
+		{
+			name:       "should_error_for_unknown_variable",
+			s:          "app={app_id}&group={group_id}&event={event_id}",
+			wantErrMsg: "variable group_id does not exist in variable map",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplaceVariableRefs(tt.s, lookup)
+			if tt.wantErrMsg != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}